@@ -1,10 +1,16 @@
 package main
 
 import (
-	"encoding/json" // For parsing JSON
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"net/http"      // For the HTTP client
-	"time"          // Good practice to add timeouts
+	"os"
+	"os/signal"
+	"time" // Good practice to add timeouts
+
+	"client/httpclient" // Pooled client with optional mTLS (see httpclient/httpclient.go)
 )
 
 // Post represents the structure of the JSON returned by the API
@@ -17,41 +23,126 @@ type Post struct {
 }
 
 func main() {
-	// 1. BEST PRACTICE: Always define a timeout for your client.
-	// A default http.Client{} has no timeout and can hang forever.
-	client := &http.Client{
-		Timeout: time.Second * 10,
+	// 1. BEST PRACTICE: Always bound how long a call may take.
+	// A default http.Client{} has no timeout and can hang forever. Here the context in step 3 is
+	// that bound: a client-wide Timeout would also cover retries and backoff and cut the call off
+	// before that deadline. Per attempt, dial and TLS timeouts still apply.
+	// CLIENT_CERT / CLIENT_KEY / CA_FILE turn on mTLS, e.g. against http-server:
+	//   API_BASE_URL=https://localhost:3000 CLIENT_CERT=client-orders-service.pem \
+	//   CLIENT_KEY=client-orders-service-key.pem CA_FILE=ca.pem API_PATH=/orders go run .
+	client, err := httpclient.New(httpclient.Config{
+		CertFile: os.Getenv("CLIENT_CERT"),
+		KeyFile:  os.Getenv("CLIENT_KEY"),
+		CAFile:   os.Getenv("CA_FILE"),
+		Timeout:  httpclient.NoTimeout,
+	})
+	if err != nil {
+		fmt.Printf("❌ Error building client: %v\n", err)
+		return
 	}
+	// HTTP_FIXTURES replays recorded responses instead of using the network; with HTTP_RECORD=1 it
+	// records them instead (see httpclient/recorder.go). Lowest in the chain, where the network would be.
+	if fixtures := os.Getenv("HTTP_FIXTURES"); fixtures != "" {
+		mode := httpclient.ModeReplay
+		if os.Getenv("HTTP_RECORD") == "1" {
+			mode = httpclient.ModeRecord
+		}
+		recorder, err := httpclient.NewRecorder(fixtures, client.Transport, httpclient.RecorderConfig{Mode: mode, Strict: true})
+		if err != nil {
+			fmt.Printf("❌ Error loading fixtures: %v\n", err)
+			return
+		}
+		defer func() {
+			if err := recorder.Save(); err != nil {
+				fmt.Printf("❌ Error saving fixtures: %v\n", err)
+			}
+		}()
+		client.Transport = recorder
+	}
+
+	// Stop calling a host that keeps failing, and probe it again after a cool-down (see httpclient/breaker.go)
+	client.Transport = httpclient.NewBreakerTransport(client.Transport, httpclient.BreakerConfig{
+		OnStateChange: func(host string, from, to httpclient.BreakerState) {
+			fmt.Printf("⚡ Circuit for %s: %s -> %s\n", host, from, to)
+		},
+	})
 
-	// 2. Make the GET request
-	resp, err := client.Get("https://jsonplaceholder.typicode.com/posts/1")
+	// Transient failures (network errors, 429, 5xx) are retried with backoff (see httpclient/retry.go)
+	client.Transport = httpclient.NewRetryTransport(client.Transport, httpclient.RetryPolicy{
+		MaxAttempts: 4,
+		OnAttempt: func(a httpclient.Attempt) {
+			if a.Retrying {
+				fmt.Printf("↻ Attempt %d failed (%s), retrying in %s\n", a.Number, attemptOutcome(a), a.Delay.Round(time.Millisecond))
+			}
+		},
+	})
+
+	// Reuse fresh responses and revalidate stale ones with ETag / Last-Modified (see httpclient/cache.go).
+	// Outermost, so a cache hit never touches the retry or breaker logic. HTTP_CACHE_DIR keeps it across runs.
+	var cacheStore httpclient.CacheStore = httpclient.NewMemoryCache(256)
+	if dir := os.Getenv("HTTP_CACHE_DIR"); dir != "" {
+		if cacheStore, err = httpclient.NewDiskCache(dir); err != nil {
+			fmt.Printf("❌ Error opening cache: %v\n", err)
+			return
+		}
+	}
+	cache := httpclient.NewCacheTransport(client.Transport, cacheStore)
+	client.Transport = cache
+
+	// 2. One API value per upstream: base URL, default headers and the client above (see httpclient/api.go)
+	api, err := httpclient.NewAPI(cmp.Or(os.Getenv("API_BASE_URL"), "https://jsonplaceholder.typicode.com"), client)
 	if err != nil {
-		fmt.Printf("❌ Error making GET Request: %v\n", err)
+		fmt.Printf("❌ Error building API client: %v\n", err)
 		return
 	}
+	path := cmp.Or(os.Getenv("API_PATH"), "/posts/1")
 
-	// 3. IMPORTANT: Always close the body to prevent memory leaks.
-	// defer ensures it runs even if the function exits early.
-	defer resp.Body.Close()
+	// 3. The context bounds all attempts together and cancels the call on Ctrl+C
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
 
-	// 4. Check the Status Code
-	if resp.StatusCode != http.StatusOK {
-		fmt.Printf("⚠️ Server returned status: %d\n", resp.StatusCode)
+	// 4. GET + status check + body close + DECODING in one typed call.
+	// The body is still decoded as a stream, never read into memory first.
+	myPost, err := httpclient.Get[Post](ctx, api, path, nil)
+	var apiErr *httpclient.APIError
+	if errors.As(err, &apiErr) {
+		fmt.Printf("⚠️ Server returned status: %d %s\n", apiErr.StatusCode, apiErr.Message)
 		return
 	}
-
-	// 5. DECODING (Streaming approach)
-	// Instead of reading all bytes into memory first (io.ReadAll), 
-	// we decode the stream directly into our struct.
-	var myPost Post
-	err = json.NewDecoder(resp.Body).Decode(&myPost)
 	if err != nil {
-		fmt.Printf("❌ Error decoding JSON: %v\n", err)
+		fmt.Printf("❌ Error making GET Request: %v\n", err)
 		return
 	}
 
-	// 6. Access your data
+	// 5. Access your data
 	fmt.Println("✅ Successfully fetched post:")
 	fmt.Printf("Title: %s\n", myPost.Title)
 	fmt.Printf("Body:  %s\n", myPost.Body)
-}
\ No newline at end of file
+
+	// 6. Stream a whole collection one page at a time (see httpclient/paginate.go), e.g. API_LIST_PATH=/orders.
+	// Only the current page is in memory; the next one is requested when the loop reaches it.
+	if listPath := os.Getenv("API_LIST_PATH"); listPath != "" {
+		count := 0
+		for _, err := range httpclient.Paginate[json.RawMessage](ctx, api, listPath, nil, httpclient.Pagination{Limit: 20}) {
+			if err != nil {
+				fmt.Printf("❌ Error listing %s after %d item(s): %v\n", listPath, count, err)
+				return
+			}
+			count++
+		}
+		fmt.Printf("✅ Listed %d item(s) from %s\n", count, listPath)
+	}
+
+	stats := cache.Stats()
+	fmt.Printf("Cache: %d hit(s), %d miss(es), %d revalidation(s)\n", stats.Hits, stats.Misses, stats.Revalidations)
+}
+
+// attemptOutcome: "503 Service Unavailable" or the network error of a failed attempt
+func attemptOutcome(a httpclient.Attempt) string {
+	if a.Err != nil {
+		return a.Err.Error()
+	}
+	return a.Response.Status
+}
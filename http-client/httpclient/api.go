@@ -0,0 +1,232 @@
+package httpclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+/**
+ * FUTURE REFERENCE: TYPED JSON CALLS
+ * ----------------------------------
+ * Every JSON call repeats the same steps: build the URL, encode the body, send, close the
+ * body, check the status, decode. API does them once; the generic helpers add the types:
+ *   post, err := httpclient.Get[Post](ctx, api, "/posts/1", nil)
+ *   created, err := httpclient.Post[NewOrder, Order](ctx, api, "/orders", newOrder)
+ * Go methods cannot have type parameters, which is why these are functions taking the API.
+ * A non-2xx status comes back as *APIError with the body, already decoded if it has the
+ * {"error","message","details"} shape http-server uses.
+ */
+
+// maxErrorBody: How much of an error response is kept in APIError
+const maxErrorBody = 64 << 10
+
+// API: A JSON API below BaseURL, called through Client with Header added to every request
+type API struct {
+	BaseURL *url.URL
+	Client  *http.Client
+	Header  http.Header
+}
+
+// NewAPI: client may be nil (http.DefaultClient). Paths passed to the calls are joined onto baseURL.
+func NewAPI(baseURL string, client *http.Client) (*API, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("httpclient: base URL: %w", err)
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("httpclient: base URL %q must be absolute", baseURL)
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	header := make(http.Header)
+	header.Set("Accept", "application/json")
+	return &API{BaseURL: u, Client: client, Header: header}, nil
+}
+
+// APIError: A non-2xx response
+type APIError struct {
+	Method     string
+	URL        string
+	StatusCode int
+	Header     http.Header
+	Body       []byte // Raw body (at most 64 KiB)
+
+	// Code / Message / Details: Filled when the body has http-server's apiError shape
+	Code    string
+	Message string
+	Details map[string]any // Values are whatever JSON holds: strings, numbers (float64), objects, ...
+}
+
+func (e *APIError) Error() string {
+	msg := fmt.Sprintf("%s %s: %d %s", e.Method, e.URL, e.StatusCode, http.StatusText(e.StatusCode))
+	if e.Code != "" {
+		msg += fmt.Sprintf(" (%s: %s)", e.Code, e.Message)
+	}
+	return msg
+}
+
+// DecodeError: Decodes the body of an *APIError inside err into E, for APIs with their own error shape
+func DecodeError[E any](err error) (E, bool) {
+	var body E
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || json.Unmarshal(apiErr.Body, &body) != nil {
+		return body, false
+	}
+	return body, true
+}
+
+// URL: BaseURL with path appended and query set. A query in path ("/orders?status=paid") is kept,
+// and keys in query replace the same keys there; JoinPath alone would escape the "?" into the path.
+func (a *API) URL(path string, query url.Values) string {
+	path, rawQuery, hasQuery := strings.Cut(path, "?")
+	u := a.BaseURL.JoinPath(path)
+	if len(query) > 0 {
+		merged, _ := url.ParseQuery(rawQuery) // Keeps the well-formed pairs of a sloppy query
+		for key, values := range query {
+			merged[key] = values
+		}
+		u.RawQuery = merged.Encode()
+	} else if hasQuery {
+		u.RawQuery = rawQuery
+	}
+	return u.String()
+}
+
+// Do: Sends in (nil = no body) as JSON and decodes a 2xx response into out (nil = discard).
+// The call is cancelled with ctx.
+func (a *API) Do(ctx context.Context, method, path string, query url.Values, in, out any) error {
+	resp, err := a.send(ctx, method, a.URL(path, query), in)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return decodeResponse(resp, out)
+}
+
+// send: Builds and sends the request; a non-2xx response is returned as *APIError with its body closed
+func (a *API) send(ctx context.Context, method, target string, in any) (*http.Response, error) {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return nil, fmt.Errorf("httpclient: encode request: %w", err)
+		}
+		body = bytes.NewReader(data) // Replayable, so the retry transport may resend it
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header = a.Header.Clone()
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := a.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		defer resp.Body.Close()
+		return nil, newAPIError(req, resp)
+	}
+	return resp, nil
+}
+
+func newAPIError(req *http.Request, resp *http.Response) *APIError {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+	apiErr := &APIError{
+		Method:     req.Method,
+		URL:        req.URL.String(),
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header,
+		Body:       body,
+	}
+
+	var shaped struct {
+		Error   string         `json:"error"`
+		Message string         `json:"message"`
+		Details map[string]any `json:"details"`
+	}
+	if json.Unmarshal(body, &shaped) == nil {
+		apiErr.Code, apiErr.Message, apiErr.Details = shaped.Error, shaped.Message, shaped.Details
+	}
+	return apiErr
+}
+
+// decodeResponse: 204 and out == nil skip decoding
+func decodeResponse(resp *http.Response, out any) error {
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		io.Copy(io.Discard, resp.Body) // Lets the connection be reused
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("httpclient: decode %s response: %w", resp.Request.URL, err)
+	}
+	return nil
+}
+
+// Get: GET path, decoded into T
+func Get[T any](ctx context.Context, api *API, path string, query url.Values) (T, error) {
+	var out T
+	err := api.Do(ctx, http.MethodGet, path, query, nil, &out)
+	return out, err
+}
+
+// List: GET a collection, either a bare JSON array or an {"items": [...]} envelope like http-server's
+func List[T any](ctx context.Context, api *API, path string, query url.Values) ([]T, error) {
+	var raw json.RawMessage
+	if err := api.Do(ctx, http.MethodGet, path, query, nil, &raw); err != nil {
+		return nil, err
+	}
+	return decodeItems[T](raw)
+}
+
+// decodeItems: A bare JSON array, or the "items" of an envelope object
+func decodeItems[T any](raw json.RawMessage) ([]T, error) {
+	var items []T
+	if strings.HasPrefix(strings.TrimSpace(string(raw)), "{") {
+		var envelope struct {
+			Items []T `json:"items"`
+		}
+		err := json.Unmarshal(raw, &envelope)
+		return envelope.Items, err
+	}
+	err := json.Unmarshal(raw, &items)
+	return items, err
+}
+
+// Post: POST in as JSON, decode the response into Resp
+func Post[Req, Resp any](ctx context.Context, api *API, path string, in Req) (Resp, error) {
+	var out Resp
+	err := api.Do(ctx, http.MethodPost, path, nil, in, &out)
+	return out, err
+}
+
+// Put: PUT in as JSON, decode the response into Resp
+func Put[Req, Resp any](ctx context.Context, api *API, path string, in Req) (Resp, error) {
+	var out Resp
+	err := api.Do(ctx, http.MethodPut, path, nil, in, &out)
+	return out, err
+}
+
+// Patch: PATCH in as JSON, decode the response into Resp
+func Patch[Req, Resp any](ctx context.Context, api *API, path string, in Req) (Resp, error) {
+	var out Resp
+	err := api.Do(ctx, http.MethodPatch, path, nil, in, &out)
+	return out, err
+}
+
+// Delete: DELETE path, ignoring any response body
+func Delete(ctx context.Context, api *API, path string) error {
+	return api.Do(ctx, http.MethodDelete, path, nil, nil, nil)
+}
@@ -0,0 +1,31 @@
+package httpclient
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestAPIURL(t *testing.T) {
+	api, err := NewAPI("https://api.example.com/v1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		path  string
+		query url.Values
+		want  string
+	}{
+		{"plain path", "/orders", nil, "https://api.example.com/v1/orders"},
+		{"query argument", "/orders", url.Values{"status": {"paid"}}, "https://api.example.com/v1/orders?status=paid"},
+		{"query in path", "/orders?status=paid&limit=5", nil, "https://api.example.com/v1/orders?status=paid&limit=5"},
+		{"both, different keys", "/orders?status=paid", url.Values{"limit": {"5"}}, "https://api.example.com/v1/orders?limit=5&status=paid"},
+		{"both, same key", "/orders?status=paid&limit=5", url.Values{"status": {"open"}}, "https://api.example.com/v1/orders?limit=5&status=open"},
+	}
+	for _, tt := range tests {
+		if got := api.URL(tt.path, tt.query); got != tt.want {
+			t.Errorf("%s: URL(%q, %v) = %s, want %s", tt.name, tt.path, tt.query, got, tt.want)
+		}
+	}
+}
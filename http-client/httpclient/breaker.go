@@ -0,0 +1,219 @@
+package httpclient
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+/**
+ * FUTURE REFERENCE: CIRCUIT BREAKER
+ * ---------------------------------
+ * When an upstream is down, every call still waits for a timeout and adds load to a service
+ * that is trying to come back. The breaker counts failures per host and short-circuits:
+ *   closed    -> requests flow; FailureThreshold failures in a row open the circuit
+ *   open      -> requests fail immediately with ErrCircuitOpen (or the Fallback answers)
+ *   half-open -> after CoolDown a few trial requests go through; if they succeed the
+ *                circuit closes, one failure opens it again for another CoolDown
+ * Put it BELOW the retry transport so retries count as separate failures, and the retry
+ * transport stops as soon as the circuit opens.
+ * Every transition starts a new generation, and a result only counts in the generation its
+ * request was admitted in: a slow answer from before the circuit opened says nothing about
+ * whether the upstream has recovered.
+ */
+
+// ErrCircuitOpen: Returned (wrapped) for requests rejected by an open circuit
+var ErrCircuitOpen = errors.New("httpclient: circuit open")
+
+// BreakerState: closed, open or half-open
+type BreakerState int
+
+const (
+	StateClosed BreakerState = iota
+	StateOpen
+	StateHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// BreakerConfig: The zero value opens after 5 failures for 30s and sends 1 trial request
+type BreakerConfig struct {
+	FailureThreshold int           // Consecutive failures that open the circuit (default 5)
+	CoolDown         time.Duration // How long the circuit stays open (default 30s)
+	HalfOpenRequests int           // Trial requests that must succeed to close it again (default 1)
+
+	// IsFailure: What counts against the upstream (default: network errors and 5xx)
+	IsFailure func(resp *http.Response, err error) bool
+
+	// Fallback: Answers requests while the circuit is open (default: return the ErrCircuitOpen error)
+	Fallback func(req *http.Request, err error) (*http.Response, error)
+
+	// OnStateChange: Called on every transition, outside the breaker's lock
+	OnStateChange func(host string, from, to BreakerState)
+}
+
+// circuit: The state of one host
+type circuit struct {
+	state      BreakerState
+	generation uint64    // Bumped on every transition; results from an earlier one are ignored
+	failures   int       // Consecutive failures while closed
+	openedAt   time.Time // When the circuit last opened
+	trials     int       // Trial requests started while half-open
+	successes  int       // Trial requests that succeeded while half-open
+}
+
+// moveTo: Changes state and starts a new generation
+func (c *circuit) moveTo(state BreakerState) {
+	c.state = state
+	c.generation++
+}
+
+// BreakerTransport: An http.RoundTripper with one circuit per host (host:port)
+type BreakerTransport struct {
+	Base   http.RoundTripper
+	Config BreakerConfig
+
+	mu    sync.Mutex
+	hosts map[string]*circuit
+}
+
+// NewBreakerTransport: Wraps base (nil = http.DefaultTransport)
+func NewBreakerTransport(base http.RoundTripper, cfg BreakerConfig) *BreakerTransport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &BreakerTransport{Base: base, Config: cfg, hosts: make(map[string]*circuit)}
+}
+
+// State: The current state of host's circuit (closed for hosts never called)
+func (t *BreakerTransport) State(host string) BreakerState {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if c, ok := t.hosts[host]; ok {
+		return c.state
+	}
+	return StateClosed
+}
+
+// RoundTrip: Implements http.RoundTripper
+func (t *BreakerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	host := req.URL.Host
+
+	generation, err := t.allow(host)
+	if err != nil {
+		if t.Config.Fallback != nil {
+			return t.Config.Fallback(req, err)
+		}
+		return nil, err
+	}
+
+	resp, err := t.Base.RoundTrip(req)
+	if err != nil && req.Context().Err() != nil {
+		// We gave up (cancel/deadline); that says nothing about the upstream. Free a trial slot if we held one.
+		t.record(host, generation, false, true)
+		return resp, err
+	}
+	t.record(host, generation, t.isFailure(resp, err), false)
+	return resp, err
+}
+
+func (t *BreakerTransport) isFailure(resp *http.Response, err error) bool {
+	if t.Config.IsFailure != nil {
+		return t.Config.IsFailure(resp, err)
+	}
+	return err != nil || resp.StatusCode >= 500
+}
+
+// allow: Decides whether a request may go out, moving open -> half-open once the cool-down is over.
+// Returns the generation the request is admitted in, for record.
+func (t *BreakerTransport) allow(host string) (uint64, error) {
+	t.mu.Lock()
+	c, ok := t.hosts[host]
+	if !ok {
+		c = &circuit{}
+		t.hosts[host] = c
+	}
+
+	from := c.state
+	var err error
+	switch c.state {
+	case StateOpen:
+		coolDown := cmp.Or(t.Config.CoolDown, 30*time.Second)
+		if retryAt := c.openedAt.Add(coolDown); time.Now().Before(retryAt) {
+			err = fmt.Errorf("%w: %s (retry after %s)", ErrCircuitOpen, host, retryAt.Format(time.RFC3339))
+			break
+		}
+		c.moveTo(StateHalfOpen)
+		c.trials, c.successes = 1, 0
+	case StateHalfOpen:
+		if c.trials >= cmp.Or(t.Config.HalfOpenRequests, 1) {
+			err = fmt.Errorf("%w: %s (half-open, trial in progress)", ErrCircuitOpen, host)
+			break
+		}
+		c.trials++
+	}
+	to, generation := c.state, c.generation
+	t.mu.Unlock()
+
+	t.notify(host, from, to)
+	return generation, err
+}
+
+// record: Feeds one result of a request admitted in generation into host's circuit.
+// abandoned results only release a half-open trial slot.
+func (t *BreakerTransport) record(host string, generation uint64, failed, abandoned bool) {
+	t.mu.Lock()
+	c := t.hosts[host]
+	if c.generation != generation {
+		t.mu.Unlock()
+		return // Admitted before the last transition
+	}
+	from := c.state
+	switch {
+	case abandoned:
+		if c.state == StateHalfOpen {
+			c.trials--
+		}
+	case c.state == StateClosed && failed:
+		c.failures++
+		if c.failures >= cmp.Or(t.Config.FailureThreshold, 5) {
+			c.moveTo(StateOpen)
+			c.openedAt = time.Now()
+		}
+	case c.state == StateClosed:
+		c.failures = 0
+	case c.state == StateHalfOpen && failed:
+		c.moveTo(StateOpen)
+		c.openedAt = time.Now()
+	case c.state == StateHalfOpen:
+		c.successes++
+		if c.successes >= cmp.Or(t.Config.HalfOpenRequests, 1) {
+			c.moveTo(StateClosed)
+			c.failures = 0
+		}
+	}
+	to := c.state
+	t.mu.Unlock()
+
+	t.notify(host, from, to)
+}
+
+func (t *BreakerTransport) notify(host string, from, to BreakerState) {
+	if from != to && t.Config.OnStateChange != nil {
+		t.Config.OnStateChange(host, from, to)
+	}
+}
@@ -0,0 +1,351 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// flakyServer: An httptest server that answers 503 while failing is set, 200 otherwise.
+// While hold is non-nil, requests wait on it before answering.
+type flakyServer struct {
+	*httptest.Server
+	failing atomic.Bool
+	calls   atomic.Int32
+	hold    chan struct{}
+}
+
+func newFlakyServer(t *testing.T) *flakyServer {
+	t.Helper()
+	s := &flakyServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.calls.Add(1)
+		if s.hold != nil {
+			<-s.hold
+		}
+		if s.failing.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// transitions: Records OnStateChange calls in order
+type transitions struct {
+	mu  sync.Mutex
+	log []string
+}
+
+func (tr *transitions) record(_ string, from, to BreakerState) {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	tr.log = append(tr.log, fmt.Sprintf("%s->%s", from, to))
+}
+
+func (tr *transitions) String() string {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	return strings.Join(tr.log, " ")
+}
+
+// get: One GET through client; returns the status (0 on error) and the error
+func get(client *http.Client, target string) (int, error) {
+	req, err := http.NewRequest(http.MethodGet, target, nil)
+	if err != nil {
+		return 0, err
+	}
+	return do(client, req)
+}
+
+// do: Like get, for a prepared request
+func do(client *http.Client, req *http.Request) (int, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	return resp.StatusCode, nil
+}
+
+func TestBreakerOpensAfterThreshold(t *testing.T) {
+	srv := newFlakyServer(t)
+	srv.failing.Store(true)
+	breaker := NewBreakerTransport(nil, BreakerConfig{FailureThreshold: 3, CoolDown: time.Minute})
+	client := &http.Client{Transport: breaker}
+	host := srv.Listener.Addr().String()
+
+	for i := 1; i <= 3; i++ {
+		if status, err := get(client, srv.URL); status != http.StatusServiceUnavailable {
+			t.Fatalf("request %d: got %d, %v; want the server's 503", i, status, err)
+		}
+		if want := StateClosed; i < 3 && breaker.State(host) != want {
+			t.Fatalf("after %d failures: state %s, want %s", i, breaker.State(host), want)
+		}
+	}
+	if breaker.State(host) != StateOpen {
+		t.Fatalf("after 3 failures: state %s, want open", breaker.State(host))
+	}
+
+	_, err := get(client, srv.URL)
+	if !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("request while open: err = %v, want ErrCircuitOpen", err)
+	}
+	if srv.calls.Load() != 3 {
+		t.Errorf("server saw %d calls, want 3 (open circuit must not reach it)", srv.calls.Load())
+	}
+}
+
+func TestBreakerSuccessResetsFailureCount(t *testing.T) {
+	srv := newFlakyServer(t)
+	breaker := NewBreakerTransport(nil, BreakerConfig{FailureThreshold: 2})
+	client := &http.Client{Transport: breaker}
+
+	for _, failing := range []bool{true, false, true, false, true} {
+		srv.failing.Store(failing)
+		get(client, srv.URL)
+	}
+	if state := breaker.State(srv.Listener.Addr().String()); state != StateClosed {
+		t.Errorf("state %s, want closed (failures were never consecutive)", state)
+	}
+}
+
+func TestBreakerHalfOpenAfterCoolDown(t *testing.T) {
+	srv := newFlakyServer(t)
+	srv.failing.Store(true)
+	var tr transitions
+	breaker := NewBreakerTransport(nil, BreakerConfig{FailureThreshold: 1, CoolDown: 30 * time.Millisecond, OnStateChange: tr.record})
+	client := &http.Client{Transport: breaker}
+
+	get(client, srv.URL) // Opens
+
+	// Trial fails: back to open for another cool-down
+	time.Sleep(50 * time.Millisecond)
+	if status, _ := get(client, srv.URL); status != http.StatusServiceUnavailable {
+		t.Fatalf("trial request: got %d, want it to reach the server", status)
+	}
+	if _, err := get(client, srv.URL); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("after failed trial: err = %v, want ErrCircuitOpen", err)
+	}
+
+	// Trial succeeds: closed again
+	srv.failing.Store(false)
+	time.Sleep(50 * time.Millisecond)
+	if status, err := get(client, srv.URL); status != http.StatusOK {
+		t.Fatalf("trial request: got %d, %v", status, err)
+	}
+
+	want := "closed->open open->half-open half-open->open open->half-open half-open->closed"
+	if got := tr.String(); got != want {
+		t.Errorf("transitions:\n got %s\nwant %s", got, want)
+	}
+}
+
+func TestBreakerHalfOpenTrialLimit(t *testing.T) {
+	srv := newFlakyServer(t)
+	srv.failing.Store(true)
+	breaker := NewBreakerTransport(nil, BreakerConfig{FailureThreshold: 1, CoolDown: 20 * time.Millisecond, HalfOpenRequests: 1})
+	client := &http.Client{Transport: breaker}
+	host := srv.Listener.Addr().String()
+
+	get(client, srv.URL) // Opens
+	time.Sleep(40 * time.Millisecond)
+	srv.failing.Store(false)
+	srv.hold = make(chan struct{})
+
+	trial := make(chan int)
+	go func() {
+		status, _ := get(client, srv.URL)
+		trial <- status
+	}()
+	for srv.calls.Load() < 2 {
+		time.Sleep(time.Millisecond) // Wait until the trial is inside the server
+	}
+
+	if breaker.State(host) != StateHalfOpen {
+		t.Fatalf("during the trial: state %s, want half-open", breaker.State(host))
+	}
+	if _, err := get(client, srv.URL); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("second request during the trial: err = %v, want ErrCircuitOpen", err)
+	}
+
+	close(srv.hold)
+	if status := <-trial; status != http.StatusOK {
+		t.Fatalf("trial request: got %d", status)
+	}
+	if breaker.State(host) != StateClosed {
+		t.Errorf("after a good trial: state %s, want closed", breaker.State(host))
+	}
+	if srv.calls.Load() != 2 {
+		t.Errorf("server saw %d calls, want 2", srv.calls.Load())
+	}
+}
+
+func TestBreakerFallback(t *testing.T) {
+	srv := newFlakyServer(t)
+	srv.failing.Store(true)
+	breaker := NewBreakerTransport(nil, BreakerConfig{
+		FailureThreshold: 1,
+		CoolDown:         time.Minute,
+		Fallback: func(req *http.Request, err error) (*http.Response, error) {
+			if !errors.Is(err, ErrCircuitOpen) {
+				t.Errorf("fallback got err %v, want ErrCircuitOpen", err)
+			}
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{"X-Fallback": {"1"}},
+				Body:       io.NopCloser(strings.NewReader("cached")),
+				Request:    req,
+			}, nil
+		},
+	})
+	client := &http.Client{Transport: breaker}
+
+	get(client, srv.URL) // Opens
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.Header.Get("X-Fallback") != "1" || string(body) != "cached" {
+		t.Errorf("got %q (X-Fallback %q), want the fallback response", body, resp.Header.Get("X-Fallback"))
+	}
+	if srv.calls.Load() != 1 {
+		t.Errorf("server saw %d calls, want 1", srv.calls.Load())
+	}
+}
+
+func TestBreakerIsPerHost(t *testing.T) {
+	bad, good := newFlakyServer(t), newFlakyServer(t)
+	bad.failing.Store(true)
+	breaker := NewBreakerTransport(nil, BreakerConfig{FailureThreshold: 1, CoolDown: time.Minute})
+	client := &http.Client{Transport: breaker}
+
+	get(client, bad.URL)
+	if status, err := get(client, good.URL); status != http.StatusOK {
+		t.Errorf("other host: got %d, %v; want 200", status, err)
+	}
+	if state := breaker.State(bad.Listener.Addr().String()); state != StateOpen {
+		t.Errorf("failing host: state %s, want open", state)
+	}
+}
+
+// heldServer: Answers 503 on /fail and 200 on /ok; every other path waits until released, then answers 200
+type heldServer struct {
+	*httptest.Server
+	mu      sync.Mutex
+	waiting map[string]chan struct{}
+}
+
+func newHeldServer(t *testing.T) *heldServer {
+	t.Helper()
+	s := &heldServer{waiting: make(map[string]chan struct{})}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/fail":
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		case "/ok":
+			w.Write([]byte("ok"))
+			return
+		}
+		select {
+		case <-s.arrived(r.URL.Path):
+		case <-r.Context().Done():
+		}
+		w.Write([]byte("ok"))
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// arrived: Marks path as waiting and returns the channel that releases it
+func (s *heldServer) arrived(path string) chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.waiting[path] == nil {
+		s.waiting[path] = make(chan struct{})
+	}
+	return s.waiting[path]
+}
+
+// await: Blocks until a request for path is inside the server
+func (s *heldServer) await(path string) {
+	for {
+		s.mu.Lock()
+		_, ok := s.waiting[path]
+		s.mu.Unlock()
+		if ok {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// start: Sends req in the background; the returned channel yields its status (0 on error)
+func start(client *http.Client, req *http.Request) chan int {
+	status := make(chan int, 1)
+	go func() {
+		code, _ := do(client, req)
+		status <- code
+	}()
+	return status
+}
+
+func TestBreakerIgnoresResultsFromBeforeATransition(t *testing.T) {
+	srv := newHeldServer(t)
+	breaker := NewBreakerTransport(nil, BreakerConfig{FailureThreshold: 1, CoolDown: 20 * time.Millisecond, HalfOpenRequests: 1})
+	client := &http.Client{Transport: breaker}
+	host := srv.Listener.Addr().String()
+
+	// Admitted while closed, answered only after the circuit opened and went half-open
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	staleReq, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/stale", nil)
+	slowReq, _ := http.NewRequest(http.MethodGet, srv.URL+"/slow", nil)
+	stale, slow := start(client, staleReq), start(client, slowReq)
+	srv.await("/stale")
+	srv.await("/slow")
+
+	get(client, srv.URL+"/fail") // Opens
+	time.Sleep(40 * time.Millisecond)
+	trialReq, _ := http.NewRequest(http.MethodGet, srv.URL+"/trial", nil)
+	trial := start(client, trialReq)
+	srv.await("/trial")
+
+	// A success from the closed period must not close the circuit
+	close(srv.arrived("/slow"))
+	if status := <-slow; status != http.StatusOK {
+		t.Fatalf("slow request: got %d", status)
+	}
+	if state := breaker.State(host); state != StateHalfOpen {
+		t.Errorf("after a late success: state %s, want half-open (the trial has not answered yet)", state)
+	}
+
+	// Giving up on a request from the closed period must not free the trial slot
+	cancel()
+	<-stale
+	if _, err := get(client, srv.URL+"/ok"); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("second request during the trial: err = %v, want ErrCircuitOpen", err)
+	}
+
+	close(srv.arrived("/trial"))
+	if status := <-trial; status != http.StatusOK {
+		t.Fatalf("trial request: got %d", status)
+	}
+	if state := breaker.State(host); state != StateClosed {
+		t.Errorf("after a good trial: state %s, want closed", state)
+	}
+}
@@ -0,0 +1,409 @@
+package httpclient
+
+import (
+	"bufio"
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+/**
+ * FUTURE REFERENCE: HTTP CACHING (client side, private cache)
+ * -----------------------------------------------------------
+ * The server tells us how long a response stays valid and how to check it cheaply afterwards:
+ *   Cache-Control: max-age=60        -> reuse it for 60s without asking (a "hit")
+ *   ETag: "v3" / Last-Modified: ...  -> when stale, ask "If-None-Match: "v3"" and get a
+ *                                       body-less 304 if nothing changed (a "revalidation")
+ *   Cache-Control: no-store          -> never keep it; no-cache -> keep it, but always revalidate
+ * Only GET responses are cached. A successful POST/PUT/PATCH/DELETE on a URL drops its entry.
+ * Responses from the cache carry "X-Cache: HIT" or "X-Cache: REVALIDATED" for debugging.
+ */
+
+// maxCachedBody: Larger responses are passed through without being stored
+const maxCachedBody = 10 << 20
+
+// CachedResponse: What a CacheStore keeps per URL
+type CachedResponse struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+	StoredAt   time.Time         // When the response was received (or last revalidated)
+	Vary       map[string]string // Request header values the response varies on
+}
+
+// CacheStore: A backend for CacheTransport. Implementations must be safe for concurrent use.
+type CacheStore interface {
+	Get(key string) (*CachedResponse, bool)
+	Set(key string, entry *CachedResponse)
+	Delete(key string)
+}
+
+// CacheStats: Counters since the transport was created
+type CacheStats struct {
+	Hits          uint64 // Served from the cache without contacting the server
+	Misses        uint64 // Fetched in full (nothing cached, stale without validators, or changed)
+	Revalidations uint64 // Stale entries the server confirmed with 304 Not Modified
+}
+
+// CacheTransport: An http.RoundTripper that serves GET requests from Store when HTTP allows it
+type CacheTransport struct {
+	Base  http.RoundTripper
+	Store CacheStore
+
+	hits, misses, revalidations atomic.Uint64
+}
+
+// NewCacheTransport: Wraps base (nil = http.DefaultTransport)
+func NewCacheTransport(base http.RoundTripper, store CacheStore) *CacheTransport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &CacheTransport{Base: base, Store: store}
+}
+
+// Stats: Snapshot of the hit/miss/revalidation counters
+func (t *CacheTransport) Stats() CacheStats {
+	return CacheStats{Hits: t.hits.Load(), Misses: t.misses.Load(), Revalidations: t.revalidations.Load()}
+}
+
+// RoundTrip: Implements http.RoundTripper
+func (t *CacheTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	key := req.URL.String()
+
+	if req.Method != http.MethodGet {
+		resp, err := t.Base.RoundTrip(req)
+		if err == nil && req.Method != http.MethodHead && resp.StatusCode < 400 {
+			t.Store.Delete(key) // The resource changed; whatever we hold is outdated
+		}
+		return resp, err
+	}
+
+	reqCC := parseCacheControl(req.Header.Get("Cache-Control"))
+	if _, ok := reqCC["no-store"]; ok {
+		return t.Base.RoundTrip(req)
+	}
+
+	cached, ok := t.Store.Get(key)
+	if ok && !cached.matchesVary(req) {
+		cached, ok = nil, false
+	}
+	if ok && cached.fresh(reqCC) {
+		t.hits.Add(1)
+		return cached.response(req, "HIT"), nil
+	}
+
+	// Stale (or must be revalidated): ask the server whether our copy is still good
+	outReq := req
+	if ok {
+		etag, lastModified := cached.Header.Get("ETag"), cached.Header.Get("Last-Modified")
+		if etag != "" || lastModified != "" {
+			outReq = req.Clone(req.Context())
+			if etag != "" {
+				outReq.Header.Set("If-None-Match", etag)
+			}
+			if lastModified != "" {
+				outReq.Header.Set("If-Modified-Since", lastModified)
+			}
+		}
+	}
+
+	resp, err := t.Base.RoundTrip(outReq)
+	if err != nil {
+		return nil, err
+	}
+
+	if ok && outReq != req && resp.StatusCode == http.StatusNotModified {
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+
+		// The 304 carries fresh caching headers (Date, Cache-Control, ETag, ...) for the stored body
+		updated := *cached
+		updated.Header = cached.Header.Clone()
+		for name, values := range resp.Header {
+			updated.Header[name] = values
+		}
+		updated.StoredAt = time.Now()
+		t.Store.Set(key, &updated)
+		t.revalidations.Add(1)
+		return updated.response(req, "REVALIDATED"), nil
+	}
+
+	t.misses.Add(1)
+	return t.maybeStore(key, req, resp), nil
+}
+
+// maybeStore: Buffers and stores a cacheable response; the caller gets an equivalent response either way
+func (t *CacheTransport) maybeStore(key string, req *http.Request, resp *http.Response) *http.Response {
+	respCC := parseCacheControl(resp.Header.Get("Cache-Control"))
+	if !cacheableStatus(resp.StatusCode) || resp.Header.Get("Vary") == "*" {
+		return resp
+	}
+	if _, ok := respCC["no-store"]; ok {
+		return resp
+	}
+	if freshnessLifetime(resp.Header, respCC) <= 0 && resp.Header.Get("ETag") == "" && resp.Header.Get("Last-Modified") == "" {
+		return resp // Could neither be reused nor revalidated
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxCachedBody+1))
+	if err != nil || len(body) > maxCachedBody {
+		// Too big (or broken): hand back what we read plus the rest, unstored
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), resp.Body), resp.Body}
+		return resp
+	}
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	entry := &CachedResponse{
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header.Clone(),
+		Body:       body,
+		StoredAt:   time.Now(),
+	}
+	for _, name := range strings.Split(resp.Header.Get("Vary"), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			if entry.Vary == nil {
+				entry.Vary = make(map[string]string)
+			}
+			entry.Vary[http.CanonicalHeaderKey(name)] = req.Header.Get(name)
+		}
+	}
+	t.Store.Set(key, entry)
+	return resp
+}
+
+// cacheableStatus: Statuses that are cacheable by default (RFC 9110 section 15.1), minus the exotic ones
+func cacheableStatus(status int) bool {
+	switch status {
+	case http.StatusOK, http.StatusNonAuthoritativeInfo, http.StatusNoContent,
+		http.StatusMovedPermanently, http.StatusNotFound, http.StatusGone:
+		return true
+	}
+	return false
+}
+
+// matchesVary: Whether req sends the same values for every header the response varies on
+func (c *CachedResponse) matchesVary(req *http.Request) bool {
+	for name, value := range c.Vary {
+		if req.Header.Get(name) != value {
+			return false
+		}
+	}
+	return true
+}
+
+// fresh: Whether the entry may be used without revalidation for a request with reqCC
+func (c *CachedResponse) fresh(reqCC map[string]string) bool {
+	respCC := parseCacheControl(c.Header.Get("Cache-Control"))
+	if _, ok := respCC["no-cache"]; ok {
+		return false
+	}
+	if _, ok := reqCC["no-cache"]; ok {
+		return false
+	}
+
+	lifetime := freshnessLifetime(c.Header, respCC)
+	if maxAge, ok := reqCC["max-age"]; ok {
+		if secs, err := strconv.Atoi(maxAge); err == nil {
+			lifetime = min(lifetime, time.Duration(secs)*time.Second)
+		}
+	}
+	return c.age() < lifetime
+}
+
+// age: Age header at storage time plus the time we have held it since
+func (c *CachedResponse) age() time.Duration {
+	var initial time.Duration
+	if secs, err := strconv.Atoi(c.Header.Get("Age")); err == nil {
+		initial = time.Duration(secs) * time.Second
+	}
+	return initial + time.Since(c.StoredAt)
+}
+
+// response: Rebuilds an *http.Response for req; every call gets its own body reader and headers
+func (c *CachedResponse) response(req *http.Request, cacheStatus string) *http.Response {
+	header := c.Header.Clone()
+	header.Set("Age", strconv.Itoa(int(c.age().Seconds())))
+	header.Set("X-Cache", cacheStatus)
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", c.StatusCode, http.StatusText(c.StatusCode)),
+		StatusCode:    c.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(c.Body)),
+		ContentLength: int64(len(c.Body)),
+		Request:       req,
+	}
+}
+
+// freshnessLifetime: max-age, else Expires - Date, else 10% of the time since Last-Modified (at most a day)
+func freshnessLifetime(header http.Header, cc map[string]string) time.Duration {
+	if maxAge, ok := cc["max-age"]; ok {
+		if secs, err := strconv.Atoi(maxAge); err == nil {
+			return time.Duration(secs) * time.Second
+		}
+		return 0
+	}
+
+	date, err := http.ParseTime(header.Get("Date"))
+	if err != nil {
+		date = time.Now()
+	}
+	if expires := header.Get("Expires"); expires != "" {
+		at, err := http.ParseTime(expires)
+		if err != nil {
+			return 0 // An invalid Expires ("0") means already expired
+		}
+		return at.Sub(date)
+	}
+	if lastModified, err := http.ParseTime(header.Get("Last-Modified")); err == nil && date.After(lastModified) {
+		return min(date.Sub(lastModified)/10, 24*time.Hour)
+	}
+	return 0
+}
+
+// parseCacheControl: "max-age=60, no-cache" -> {"max-age": "60", "no-cache": ""}
+func parseCacheControl(value string) map[string]string {
+	directives := make(map[string]string)
+	for _, part := range strings.Split(value, ",") {
+		name, arg, _ := strings.Cut(strings.TrimSpace(part), "=")
+		if name != "" {
+			directives[strings.ToLower(name)] = strings.Trim(arg, `"`)
+		}
+	}
+	return directives
+}
+
+// MemoryCache: An in-memory LRU CacheStore holding at most maxEntries responses
+type MemoryCache struct {
+	maxEntries int
+
+	mu      sync.Mutex
+	order   *list.List               // Front = most recently used; values are *memoryEntry
+	entries map[string]*list.Element // key -> element in order
+}
+
+type memoryEntry struct {
+	key   string
+	value *CachedResponse
+}
+
+// NewMemoryCache: maxEntries <= 0 means 1000
+func NewMemoryCache(maxEntries int) *MemoryCache {
+	if maxEntries <= 0 {
+		maxEntries = 1000
+	}
+	return &MemoryCache{maxEntries: maxEntries, order: list.New(), entries: make(map[string]*list.Element)}
+}
+
+func (m *MemoryCache) Get(key string) (*CachedResponse, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	el, ok := m.entries[key]
+	if !ok {
+		return nil, false
+	}
+	m.order.MoveToFront(el)
+	return el.Value.(*memoryEntry).value, true
+}
+
+func (m *MemoryCache) Set(key string, entry *CachedResponse) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if el, ok := m.entries[key]; ok {
+		el.Value.(*memoryEntry).value = entry
+		m.order.MoveToFront(el)
+		return
+	}
+	m.entries[key] = m.order.PushFront(&memoryEntry{key: key, value: entry})
+	if m.order.Len() > m.maxEntries {
+		oldest := m.order.Back()
+		m.order.Remove(oldest)
+		delete(m.entries, oldest.Value.(*memoryEntry).key)
+	}
+}
+
+func (m *MemoryCache) Delete(key string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if el, ok := m.entries[key]; ok {
+		m.order.Remove(el)
+		delete(m.entries, key)
+	}
+}
+
+// DiskCache: A CacheStore keeping one JSON file per URL in dir, so the cache survives restarts.
+// There is no size limit; clear the directory to reclaim space.
+type DiskCache struct {
+	dir string
+}
+
+// NewDiskCache: Creates dir if needed
+func NewDiskCache(dir string) (*DiskCache, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("httpclient: cache dir: %w", err)
+	}
+	return &DiskCache{dir: dir}, nil
+}
+
+// path: URLs are hashed so any URL maps to a safe file name
+func (d *DiskCache) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(d.dir, hex.EncodeToString(sum[:])+".json")
+}
+
+func (d *DiskCache) Get(key string) (*CachedResponse, bool) {
+	f, err := os.Open(d.path(key))
+	if err != nil {
+		return nil, false
+	}
+	defer f.Close()
+
+	var entry CachedResponse
+	if err := json.NewDecoder(bufio.NewReader(f)).Decode(&entry); err != nil {
+		return nil, false // A corrupt file is just a miss; the next Set overwrites it
+	}
+	return &entry, true
+}
+
+// Set: Write to a temp file and rename, so a concurrent Get never sees half an entry
+func (d *DiskCache) Set(key string, entry *CachedResponse) {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	tmp, err := os.CreateTemp(d.dir, ".tmp-*")
+	if err != nil {
+		return
+	}
+	_, writeErr := tmp.Write(data)
+	closeErr := tmp.Close()
+	if writeErr != nil || closeErr != nil {
+		os.Remove(tmp.Name())
+		return
+	}
+	if err := os.Rename(tmp.Name(), d.path(key)); err != nil {
+		os.Remove(tmp.Name())
+	}
+}
+
+func (d *DiskCache) Delete(key string) {
+	os.Remove(d.path(key))
+}
@@ -0,0 +1,134 @@
+// Package httpclient builds http.Clients for talking to our services, including the mTLS http-server.
+package httpclient
+
+import (
+	"cmp"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"time"
+)
+
+/**
+ * FUTURE REFERENCE: ONE CLIENT, MANY REQUESTS
+ * -------------------------------------------
+ * An http.Client is safe for concurrent use and keeps a pool of open connections in its
+ * Transport. Build ONE per upstream and reuse it; a new client per request means a new TCP +
+ * TLS handshake per request (and with mTLS that handshake includes our certificate).
+ * For mTLS against http-server the client needs two things:
+ *   - our certificate + key (created by `go run . certgen client` in http-server)
+ *   - the CA that signed the SERVER certificate (ca.pem), because it is not in the system roots
+ */
+
+// Config: How the client connects. The zero value is a plain HTTPS client with sane pool defaults.
+type Config struct {
+	// CertFile / KeyFile: Client certificate presented during the handshake (both or neither)
+	CertFile string
+	KeyFile  string
+
+	// CAFile: PEM bundle trusted for the server certificate INSTEAD of the system roots
+	CAFile string
+	// ServerName: Overrides the name checked against the server certificate (default: the URL host)
+	ServerName string
+
+	// Timeout: Limit for a whole request including reading the body (0 = 30s, NoTimeout = none).
+	// It also covers whatever wraps the transport, retries and backoff included; callers that bound
+	// each call with a context deadline instead should pass NoTimeout.
+	Timeout time.Duration
+
+	// Pool tuning; zero keeps the defaults noted on each field
+	MaxIdleConns        int           // Across all hosts (100)
+	MaxIdleConnsPerHost int           // Kept open per host between requests (10; net/http's own default of 2 is tiny)
+	MaxConnsPerHost     int           // Including active ones (0 = unlimited)
+	IdleConnTimeout     time.Duration // How long an idle connection stays in the pool (90s)
+	TLSHandshakeTimeout time.Duration // (10s)
+	DialTimeout         time.Duration // TCP connect (5s)
+
+	// DisableHTTP2: Stick to HTTP/1.1 even if the server offers h2 via ALPN
+	DisableHTTP2 bool
+}
+
+// NoTimeout: Config.Timeout value for a client without an overall limit (the request context bounds calls)
+const NoTimeout time.Duration = -1
+
+// TLSConfig: The client side tls.Config for cfg (certificate, trusted CA, TLS 1.2 minimum)
+func (cfg Config) TLSConfig() (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion: tls.VersionTLS12, // Same floor as http-server's "intermediate" profile
+		ServerName: cfg.ServerName,
+	}
+
+	if (cfg.CertFile == "") != (cfg.KeyFile == "") {
+		return nil, errors.New("httpclient: CertFile and KeyFile must be set together")
+	}
+	if cfg.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("httpclient: load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("httpclient: read CA file: %w", err)
+		}
+		roots := x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("httpclient: %s contains no PEM certificate", cfg.CAFile)
+		}
+		tlsConfig.RootCAs = roots
+	}
+	return tlsConfig, nil
+}
+
+// NewTransport: A pooled http.Transport for cfg. Wrap it (retries, caching, ...) before handing it to a client.
+func NewTransport(cfg Config) (*http.Transport, error) {
+	tlsConfig, err := cfg.TLSConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	dialer := &net.Dialer{
+		Timeout:   cmp.Or(cfg.DialTimeout, 5*time.Second),
+		KeepAlive: 30 * time.Second,
+	}
+	transport := &http.Transport{
+		Proxy:               http.ProxyFromEnvironment,
+		DialContext:         dialer.DialContext,
+		TLSClientConfig:     tlsConfig,
+		TLSHandshakeTimeout: cmp.Or(cfg.TLSHandshakeTimeout, 10*time.Second),
+		MaxIdleConns:        cmp.Or(cfg.MaxIdleConns, 100),
+		MaxIdleConnsPerHost: cmp.Or(cfg.MaxIdleConnsPerHost, 10),
+		MaxConnsPerHost:     cfg.MaxConnsPerHost,
+		IdleConnTimeout:     cmp.Or(cfg.IdleConnTimeout, 90*time.Second),
+		// A custom TLSClientConfig switches net/http's automatic HTTP/2 off; ask for it explicitly
+		ForceAttemptHTTP2: !cfg.DisableHTTP2,
+	}
+	if cfg.DisableHTTP2 {
+		// A non-nil, empty map is the documented way to disable HTTP/2
+		transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+	return transport, nil
+}
+
+// New: An http.Client using NewTransport(cfg)
+func New(cfg Config) (*http.Client, error) {
+	transport, err := NewTransport(cfg)
+	if err != nil {
+		return nil, err
+	}
+	timeout := cmp.Or(cfg.Timeout, 30*time.Second)
+	if timeout < 0 {
+		timeout = 0 // http.Client's own "no limit"
+	}
+	return &http.Client{
+		Transport: transport,
+		Timeout:   timeout,
+	}, nil
+}
@@ -0,0 +1,214 @@
+package httpclient
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"iter"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+/**
+ * FUTURE REFERENCE: WALKING PAGINATED COLLECTIONS
+ * -----------------------------------------------
+ * APIs hand out big collections one page at a time and tell us where the next page is in
+ * one of a few ways:
+ *   Link header -> Link: </orders?limit=20&offset=20>; rel="next"   (http-server, GitHub)
+ *   page number -> ?page=2&limit=20, done when a page comes back short
+ *   offset      -> ?offset=20&limit=20, same
+ *   cursor      -> {"items": [...], "next_cursor": "abc"} and ?cursor=abc for the next page
+ * Paginate returns an iterator that fetches the next page only when the loop gets there:
+ *   for o, err := range httpclient.Paginate[Order](ctx, api, "/orders", nil, httpclient.Pagination{Limit: 50}) {
+ *       if err != nil { ... }           // the loop ends after an error
+ *       if done(o) { break }            // no further page is requested
+ *   }
+ * Cancelling ctx stops the walk before the next request (and aborts the one in flight).
+ */
+
+// PageStyle: How the next page is found
+type PageStyle int
+
+const (
+	PageByLink   PageStyle = iota // Follow the rel="next" Link header (the default)
+	PageByNumber                  // Increment a 1-based page parameter
+	PageByOffset                  // Advance an offset parameter by the number of items received
+	PageByCursor                  // Send the token from the previous response body
+)
+
+// Pagination: The zero value follows Link headers with the server's default page size
+type Pagination struct {
+	Style PageStyle
+
+	Limit      int    // Page size to ask for (0 = let the server decide)
+	LimitParam string // Query parameter carrying Limit (default "limit")
+
+	// PageParam: Query parameter for the page number, offset or cursor (default "page", "offset" or "cursor")
+	PageParam string
+
+	// CursorField: Top-level field of the response body holding the next cursor (default "next_cursor")
+	CursorField string
+}
+
+// Paginate: Iterates the items of every page below path. Pages are fetched lazily, one at a time;
+// only the current page is held in memory. Each page may be a bare JSON array or an {"items": [...]} envelope.
+func Paginate[T any](ctx context.Context, api *API, path string, query url.Values, p Pagination) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		var zero T
+
+		first := cloneValues(query)
+		if p.Limit > 0 {
+			first.Set(cmp.Or(p.LimitParam, "limit"), strconv.Itoa(p.Limit))
+		}
+		if p.Style == PageByNumber {
+			first.Set(p.pageParam(), "1")
+		}
+		target := api.URL(path, first)
+
+		for target != "" {
+			if err := ctx.Err(); err != nil {
+				yield(zero, err)
+				return
+			}
+
+			items, next, err := fetchPage[T](ctx, api, target, p)
+			for _, item := range items {
+				if !yield(item, nil) {
+					return
+				}
+			}
+			if err != nil {
+				yield(zero, err)
+				return
+			}
+			if next == target {
+				return // A server pointing at the same page again would loop forever
+			}
+			target = next
+		}
+	}
+}
+
+// fetchPage: One page of items and the URL of the page after it ("" = this was the last one).
+// When only the next URL cannot be worked out, the items come back together with the error.
+func fetchPage[T any](ctx context.Context, api *API, target string, p Pagination) ([]T, string, error) {
+	resp, err := api.send(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	var raw json.RawMessage
+	if err := json.NewDecoder(resp.Body).Decode(&raw); err != nil {
+		return nil, "", fmt.Errorf("httpclient: decode %s response: %w", target, err)
+	}
+	items, err := decodeItems[T](raw)
+	if err != nil {
+		return nil, "", fmt.Errorf("httpclient: decode %s items: %w", target, err)
+	}
+
+	current, _ := url.Parse(target)
+	next, err := p.next(current, resp, raw, len(items))
+	return items, next, err
+}
+
+func (p Pagination) pageParam() string {
+	switch p.Style {
+	case PageByNumber:
+		return cmp.Or(p.PageParam, "page")
+	case PageByOffset:
+		return cmp.Or(p.PageParam, "offset")
+	case PageByCursor:
+		return cmp.Or(p.PageParam, "cursor")
+	}
+	return p.PageParam
+}
+
+// next: Works out the URL of the following page from the current one
+func (p Pagination) next(current *url.URL, resp *http.Response, raw json.RawMessage, received int) (string, error) {
+	switch p.Style {
+	case PageByLink:
+		link := nextLink(resp.Header.Values("Link"))
+		if link == "" {
+			return "", nil
+		}
+		u, err := current.Parse(link) // The link may be relative (http-server sends just path + query)
+		if err != nil {
+			return "", fmt.Errorf("httpclient: bad Link header %q: %w", link, err)
+		}
+		// Every page is sent with API.Header (tokens included), so a link must not lead anywhere else
+		if u.Scheme != current.Scheme || !strings.EqualFold(u.Host, current.Host) {
+			return "", fmt.Errorf("httpclient: Link header points to another origin (%s://%s), not following it", u.Scheme, u.Host)
+		}
+		return u.String(), nil
+
+	case PageByCursor:
+		var body map[string]json.RawMessage
+		var cursor string
+		if json.Unmarshal(raw, &body) == nil {
+			json.Unmarshal(body[cmp.Or(p.CursorField, "next_cursor")], &cursor)
+		}
+		if cursor == "" || cursor == current.Query().Get(p.pageParam()) {
+			return "", nil
+		}
+		return withParam(current, p.pageParam(), cursor), nil
+
+	default: // PageByNumber, PageByOffset
+		// An empty page, or a short one when we know the page size, means there is nothing after it
+		if received == 0 || (p.Limit > 0 && received < p.Limit) {
+			return "", nil
+		}
+		position, _ := strconv.Atoi(current.Query().Get(p.pageParam()))
+		if p.Style == PageByNumber {
+			position = max(position, 1) + 1
+		} else {
+			position += received
+		}
+		return withParam(current, p.pageParam(), strconv.Itoa(position)), nil
+	}
+}
+
+// nextLink: The target of rel="next" in RFC 8288 Link header values, e.g. `<...>; rel="next", <...>; rel="prev"`
+func nextLink(values []string) string {
+	for _, value := range values {
+		for _, link := range strings.Split(value, ",") {
+			target, params, ok := strings.Cut(strings.TrimSpace(link), ";")
+			if !ok || !strings.HasPrefix(target, "<") || !strings.HasSuffix(target, ">") {
+				continue
+			}
+			for _, param := range strings.Split(params, ";") {
+				name, val, _ := strings.Cut(strings.TrimSpace(param), "=")
+				if !strings.EqualFold(name, "rel") {
+					continue
+				}
+				// rel may hold several space-separated types: rel="next last"
+				for _, rel := range strings.Fields(strings.Trim(val, `"`)) {
+					if strings.EqualFold(rel, "next") {
+						return target[1 : len(target)-1]
+					}
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// withParam: u with one query parameter replaced
+func withParam(u *url.URL, name, value string) string {
+	query := u.Query()
+	query.Set(name, value)
+	next := *u
+	next.RawQuery = query.Encode()
+	return next.String()
+}
+
+func cloneValues(v url.Values) url.Values {
+	out := make(url.Values, len(v))
+	for k, vals := range v {
+		out[k] = append([]string(nil), vals...)
+	}
+	return out
+}
@@ -0,0 +1,76 @@
+package httpclient
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPaginateFollowsLinks(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("offset") {
+		case "":
+			w.Header().Set("Link", `</orders?offset=2>; rel="next"`)
+			w.Write([]byte(`{"items": [1, 2]}`))
+		case "2":
+			w.Write([]byte(`{"items": [3]}`))
+		}
+	}))
+	defer srv.Close()
+	api, err := NewAPI(srv.URL, srv.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []int
+	for n, err := range Paginate[int](context.Background(), api, "/orders", nil, Pagination{}) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, n)
+	}
+	if len(got) != 3 || got[2] != 3 {
+		t.Errorf("got %v, want [1 2 3]", got)
+	}
+}
+
+func TestPaginateRefusesOtherOrigins(t *testing.T) {
+	var leaked http.Header
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		leaked = r.Header
+		w.Write([]byte(`[]`))
+	}))
+	defer other.Close()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Link", "<"+other.URL+`/orders?offset=2>; rel="next"`)
+		w.Write([]byte(`[1, 2]`))
+	}))
+	defer srv.Close()
+	api, err := NewAPI(srv.URL, srv.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	api.Header.Set("Authorization", "Bearer secret")
+
+	var got []int
+	var walkErr error
+	for n, err := range Paginate[int](context.Background(), api, "/orders", nil, Pagination{}) {
+		if err != nil {
+			walkErr = err
+			break
+		}
+		got = append(got, n)
+	}
+	if len(got) != 2 {
+		t.Errorf("got %v, want the first page [1 2]", got)
+	}
+	if walkErr == nil || !strings.Contains(walkErr.Error(), "another origin") {
+		t.Errorf("err = %v, want a refusal to follow the link", walkErr)
+	}
+	if leaked != nil {
+		t.Errorf("other origin was called with Authorization %q", leaked.Get("Authorization"))
+	}
+}
@@ -0,0 +1,324 @@
+package httpclient
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"unicode/utf8"
+)
+
+/**
+ * FUTURE REFERENCE: RECORD / REPLAY
+ * ---------------------------------
+ * Code that calls a real API is hard to test: the API may be down, slow, rate limited or
+ * return different data tomorrow. Record the conversation once, replay it forever:
+ *   HTTP_FIXTURES=testdata/posts.json HTTP_RECORD=1 go run .   -> talks to the API, writes the file
+ *   HTTP_FIXTURES=testdata/posts.json go run .                 -> never touches the network
+ * The fixture file is plain JSON so it can be reviewed and edited in a diff. Secrets must not
+ * end up in it, so headers like Authorization or Set-Cookie are stored as "REDACTED".
+ * In strict replay a request with no recording fails with ErrNoFixture; otherwise it goes to
+ * the real Base transport (and is not recorded).
+ * Put the recorder at the bottom of the chain, where the network would be, so caching,
+ * retries and the breaker run on top of it exactly as in production.
+ */
+
+// ErrNoFixture: Returned (wrapped) in strict replay for a request that was never recorded
+var ErrNoFixture = errors.New("httpclient: no recorded interaction matches")
+
+// redacted: Stored instead of the value of a redacted header
+const redacted = "REDACTED"
+
+// RecordMode: Whether the Recorder talks to the network
+type RecordMode int
+
+const (
+	ModeReplay RecordMode = iota // Answer from the fixture file
+	ModeRecord                   // Send to Base and remember the interaction for Save
+)
+
+// RecorderConfig: The zero value replays leniently and redacts the usual credential headers
+type RecorderConfig struct {
+	Mode   RecordMode
+	Strict bool // Replay only: unmatched requests fail instead of going to Base
+
+	// RedactHeaders: Headers (request and response) whose values are never written
+	// (default Authorization, Proxy-Authorization, Cookie, Set-Cookie, X-Api-Key)
+	RedactHeaders []string
+
+	// Match: Whether req is the recorded request (default: same method, URL and body)
+	Match func(req *http.Request, body []byte, recorded RecordedRequest) bool
+}
+
+// Interaction: One request with its response, as stored in the fixture file
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+// RecordedRequest: The parts of a request that are stored
+type RecordedRequest struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header,omitempty"`
+	Body   Body        `json:"body,omitempty"`
+}
+
+// RecordedResponse: The parts of a response that are stored
+type RecordedResponse struct {
+	StatusCode int         `json:"status"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       Body        `json:"body,omitempty"`
+}
+
+// Body: Stored as a JSON string when it is valid UTF-8, else as {"base64": "..."}
+type Body []byte
+
+func (b Body) MarshalJSON() ([]byte, error) {
+	if utf8.Valid(b) {
+		return json.Marshal(string(b))
+	}
+	return json.Marshal(struct {
+		Base64 string `json:"base64"`
+	}{base64.StdEncoding.EncodeToString(b)})
+}
+
+func (b *Body) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		*b = Body(text)
+		return nil
+	}
+	var encoded struct {
+		Base64 string `json:"base64"`
+	}
+	if err := json.Unmarshal(data, &encoded); err != nil {
+		return err
+	}
+	raw, err := base64.StdEncoding.DecodeString(encoded.Base64)
+	*b = raw
+	return err
+}
+
+// fixtureFile: The on-disk format
+type fixtureFile struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Recorder: An http.RoundTripper that records interactions with Base to a fixture file, or replays them
+type Recorder struct {
+	Base   http.RoundTripper
+	Path   string
+	Config RecorderConfig
+
+	mu           sync.Mutex
+	interactions []Interaction
+	used         []bool // Replay: interactions already handed out
+}
+
+// NewRecorder: Wraps base (nil = http.DefaultTransport). In replay mode the fixture file at path must exist.
+func NewRecorder(path string, base http.RoundTripper, cfg RecorderConfig) (*Recorder, error) {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	r := &Recorder{Base: base, Path: path, Config: cfg}
+	if cfg.Mode == ModeRecord {
+		return r, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("httpclient: read fixtures: %w", err)
+	}
+	var file fixtureFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("httpclient: parse fixtures %s: %w", path, err)
+	}
+	r.interactions = file.Interactions
+	r.used = make([]bool, len(file.Interactions))
+	return r, nil
+}
+
+// RoundTrip: Implements http.RoundTripper
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, req, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.Config.Mode == ModeReplay {
+		if recorded, ok := r.find(req, body); ok {
+			return recorded.response(req), nil
+		}
+		if r.Config.Strict {
+			return nil, fmt.Errorf("%w: %s %s", ErrNoFixture, req.Method, req.URL)
+		}
+		return r.Base.RoundTrip(req)
+	}
+
+	resp, err := r.Base.RoundTrip(req)
+	if err != nil {
+		return nil, err // Network errors are not recorded; replay cannot reproduce them faithfully
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	r.mu.Lock()
+	r.interactions = append(r.interactions, Interaction{
+		Request: RecordedRequest{
+			Method: req.Method,
+			URL:    req.URL.String(),
+			Header: r.redact(req.Header),
+			Body:   body,
+		},
+		Response: RecordedResponse{
+			StatusCode: resp.StatusCode,
+			Header:     r.redact(resp.Header),
+			Body:       respBody,
+		},
+	})
+	r.mu.Unlock()
+	return resp, nil
+}
+
+// Save: Writes the recorded interactions to Path (record mode only; a no-op when replaying)
+func (r *Recorder) Save() error {
+	if r.Config.Mode != ModeRecord {
+		return nil
+	}
+	r.mu.Lock()
+	data, err := json.MarshalIndent(fixtureFile{Interactions: r.interactions}, "", "  ")
+	r.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("httpclient: encode fixtures: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(r.Path), 0o755); err != nil {
+		return fmt.Errorf("httpclient: write fixtures: %w", err)
+	}
+	// Temp file + rename, so an interrupted run never leaves half a fixture file behind
+	tmp := r.Path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("httpclient: write fixtures: %w", err)
+	}
+	if err := os.Rename(tmp, r.Path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("httpclient: write fixtures: %w", err)
+	}
+	return nil
+}
+
+// Unused: Recorded interactions that were never replayed, e.g. to fail a test that skipped a call
+func (r *Recorder) Unused() []Interaction {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var unused []Interaction
+	for i, used := range r.used {
+		if !used {
+			unused = append(unused, r.interactions[i])
+		}
+	}
+	return unused
+}
+
+// find: The first unused matching interaction, so repeated calls replay in recorded order;
+// once those run out the last match is reused (a poll loop keeps seeing the final answer).
+func (r *Recorder) find(req *http.Request, body []byte) (*Interaction, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	last := -1
+	for i := range r.interactions {
+		if !r.match(req, body, r.interactions[i].Request) {
+			continue
+		}
+		if !r.used[i] {
+			r.used[i] = true
+			return &r.interactions[i], true
+		}
+		last = i
+	}
+	if last >= 0 {
+		return &r.interactions[last], true
+	}
+	return nil, false
+}
+
+func (r *Recorder) match(req *http.Request, body []byte, recorded RecordedRequest) bool {
+	if r.Config.Match != nil {
+		return r.Config.Match(req, body, recorded)
+	}
+	return req.Method == recorded.Method && req.URL.String() == recorded.URL && bytes.Equal(body, recorded.Body)
+}
+
+// redact: A copy of header with the configured values replaced
+func (r *Recorder) redact(header http.Header) http.Header {
+	names := r.Config.RedactHeaders
+	if names == nil {
+		names = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "X-Api-Key"}
+	}
+	out := header.Clone()
+	for _, name := range names {
+		if values := out.Values(name); len(values) > 0 {
+			out[http.CanonicalHeaderKey(name)] = []string{redacted}
+		}
+	}
+	return out
+}
+
+// response: A fresh *http.Response for req from the recording
+func (i *Interaction) response(req *http.Request) *http.Response {
+	header := i.Response.Header.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+	header.Set("Content-Length", strconv.Itoa(len(i.Response.Body)))
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", i.Response.StatusCode, http.StatusText(i.Response.StatusCode)),
+		StatusCode:    i.Response.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(i.Response.Body)),
+		ContentLength: int64(len(i.Response.Body)),
+		Request:       req,
+	}
+}
+
+// readRequestBody: The request body, plus the request to send (a copy with a fresh body when it had to be consumed)
+func readRequestBody(req *http.Request) ([]byte, *http.Request, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, req, nil
+	}
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, nil, err
+		}
+		defer body.Close()
+		data, err := io.ReadAll(body)
+		return data, req, err
+	}
+
+	// A RoundTripper must not modify the caller's request, so send a clone with the buffered body
+	data, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, nil, err
+	}
+	out := req.Clone(req.Context())
+	out.Body = io.NopCloser(bytes.NewReader(data))
+	return data, out, nil
+}
@@ -0,0 +1,185 @@
+package httpclient
+
+import (
+	"cmp"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+/**
+ * FUTURE REFERENCE: RETRIES
+ * -------------------------
+ * A retry is only safe when doing the request twice has the same effect as doing it once
+ * (GET, PUT, DELETE, ...). A POST is retried only if it carries an Idempotency-Key header,
+ * which tells the server to deduplicate it.
+ * Waiting: attempt n sleeps a random time in [0, min(MaxDelay, BaseDelay * 2^(n-1))] ("full
+ * jitter"), so a crowd of clients that failed together does not come back together.
+ * A Retry-After header from the server (429/503) overrides that if it asks for longer.
+ * The overall deadline is the request's context: no retry starts that could not finish in time.
+ */
+
+// RetryPolicy: When and how often to retry. The zero value retries 3 attempts with 100ms..10s backoff.
+type RetryPolicy struct {
+	MaxAttempts int           // Including the first attempt (default 3)
+	BaseDelay   time.Duration // Backoff for the first retry (default 100ms)
+	MaxDelay    time.Duration // Upper bound of a single backoff (default 10s)
+
+	// RetryStatus: Which responses are retried (default: 429 and 5xx except 501)
+	RetryStatus func(status int) bool
+
+	// OnAttempt: Called after every attempt, e.g. for logs or metrics. Must not touch the body.
+	OnAttempt func(Attempt)
+}
+
+// Attempt: What OnAttempt gets to see
+type Attempt struct {
+	Number   int            // 1 for the first try
+	Request  *http.Request  // The request as sent
+	Response *http.Response // nil on a network error
+	Err      error
+	Retrying bool          // Whether another attempt follows
+	Delay    time.Duration // Wait before the next attempt (0 when not retrying)
+}
+
+// RetryTransport: An http.RoundTripper that retries idempotent requests on Base
+type RetryTransport struct {
+	Base   http.RoundTripper
+	Policy RetryPolicy
+}
+
+// NewRetryTransport: Wraps base (nil = http.DefaultTransport)
+func NewRetryTransport(base http.RoundTripper, policy RetryPolicy) *RetryTransport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &RetryTransport{Base: base, Policy: policy}
+}
+
+// RoundTrip: Implements http.RoundTripper
+func (t *RetryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	maxAttempts := cmp.Or(t.Policy.MaxAttempts, 3)
+	if !retryable(req) {
+		maxAttempts = 1
+	}
+
+	for n := 1; ; n++ {
+		attemptReq := req
+		if n > 1 && req.Body != nil && req.Body != http.NoBody {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			attemptReq = req.Clone(req.Context())
+			attemptReq.Body = body
+		}
+
+		resp, err := t.Base.RoundTrip(attemptReq)
+
+		attempt := Attempt{Number: n, Request: attemptReq, Response: resp, Err: err}
+		if n < maxAttempts && t.shouldRetry(req.Context(), resp, err) {
+			if d := t.delay(n, resp); fitsDeadline(req.Context(), d) {
+				attempt.Retrying, attempt.Delay = true, d
+			}
+		}
+		if t.Policy.OnAttempt != nil {
+			t.Policy.OnAttempt(attempt)
+		}
+		if !attempt.Retrying {
+			return resp, err
+		}
+
+		if resp != nil {
+			// Drain a little so the connection can go back to the pool, then give up on it
+			io.CopyN(io.Discard, resp.Body, 4<<10)
+			resp.Body.Close()
+		}
+
+		timer := time.NewTimer(attempt.Delay)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// retryable: Idempotent methods, or any method with an Idempotency-Key; the body must be replayable
+func retryable(req *http.Request) bool {
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false // A streamed body cannot be sent twice
+	}
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return req.Header.Get("Idempotency-Key") != ""
+}
+
+// shouldRetry: Transient network errors and the configured statuses; never a cancelled request or a bad certificate
+func (t *RetryTransport) shouldRetry(ctx context.Context, resp *http.Response, err error) bool {
+	if err != nil {
+		if ctx.Err() != nil || errors.Is(err, ErrCircuitOpen) {
+			return false // Cancelled by the caller, or the breaker already knows the upstream is down
+		}
+		var unknownAuthority x509.UnknownAuthorityError
+		var hostname x509.HostnameError
+		var invalid x509.CertificateInvalidError
+		var verify *tls.CertificateVerificationError
+		if errors.As(err, &unknownAuthority) || errors.As(err, &hostname) || errors.As(err, &invalid) || errors.As(err, &verify) {
+			return false // Will fail the same way every time
+		}
+		return true
+	}
+	if t.Policy.RetryStatus != nil {
+		return t.Policy.RetryStatus(resp.StatusCode)
+	}
+	return resp.StatusCode == http.StatusTooManyRequests ||
+		(resp.StatusCode >= 500 && resp.StatusCode != http.StatusNotImplemented)
+}
+
+// delay: Full jitter backoff for retry number n, or the server's Retry-After when that is longer
+func (t *RetryTransport) delay(n int, resp *http.Response) time.Duration {
+	base := cmp.Or(t.Policy.BaseDelay, 100*time.Millisecond)
+	ceiling := cmp.Or(t.Policy.MaxDelay, 10*time.Second)
+
+	backoff := ceiling
+	if shift := n - 1; shift < 32 && base<<shift > 0 && base<<shift < ceiling {
+		backoff = base << shift
+	}
+	d := rand.N(backoff + 1)
+
+	if resp != nil {
+		if after, ok := retryAfter(resp.Header.Get("Retry-After")); ok && after > d {
+			d = after
+		}
+	}
+	return d
+}
+
+// retryAfter: Parses "120" (seconds) or an HTTP date
+func retryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(value); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return max(time.Until(at), 0), true
+	}
+	return 0, false
+}
+
+// fitsDeadline: Whether waiting d still leaves time before the context deadline
+func fitsDeadline(ctx context.Context, d time.Duration) bool {
+	deadline, ok := ctx.Deadline()
+	return !ok || time.Until(deadline) > d
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+)
+
+/**
+ * FUTURE REFERENCE: ACCESS LOGS
+ * -----------------------------
+ * One structured record per request (instead of several free-form Printf lines) means
+ * a log pipeline can filter on "client_cn" or "status" without regular expressions.
+ * The X-Request-ID travels with the request (context + response header) so a client
+ * can quote it in a bug report and we can find the exact log line.
+ */
+
+// requestIDHeader: Propagated from the client when valid, generated otherwise
+const requestIDHeader = "X-Request-ID"
+
+// ctxKey: Private type for context keys so other packages cannot collide with ours
+type ctxKey int
+
+const (
+	requestIDKey      ctxKey = iota
+	forwardedChainKey        // Verified chain from a trusted proxy's certificate header (see proxy.go)
+)
+
+// newLogger: "json" (default) or "logfmt" records on stdout
+func newLogger(format string) *slog.Logger {
+	opts := &slog.HandlerOptions{Level: slog.LevelInfo}
+	if format == "logfmt" {
+		return slog.New(slog.NewTextHandler(os.Stdout, opts))
+	}
+	return slog.New(slog.NewJSONHandler(os.Stdout, opts))
+}
+
+// requestIDFrom: The request ID assigned by the access log middleware ("" outside a request)
+func requestIDFrom(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+// validRequestID: Only accept short, boring IDs from clients so they cannot inject into our logs
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, c := range id {
+		isAlnum := (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+		if !isAlnum && c != '-' && c != '_' && c != '.' {
+			return false
+		}
+	}
+	return true
+}
+
+// statusRecorder: Remembers the status code and body size written by the handler
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (sr *statusRecorder) WriteHeader(status int) {
+	if sr.status == 0 {
+		sr.status = status
+	}
+	sr.ResponseWriter.WriteHeader(status)
+}
+
+func (sr *statusRecorder) Write(p []byte) (int, error) {
+	if sr.status == 0 {
+		sr.status = http.StatusOK // Write without WriteHeader means 200
+	}
+	n, err := sr.ResponseWriter.Write(p)
+	sr.bytes += int64(n)
+	return n, err
+}
+
+// ReadFrom: Keeps the sendfile fast path of the underlying writer while still counting bytes
+func (sr *statusRecorder) ReadFrom(r io.Reader) (int64, error) {
+	if sr.status == 0 {
+		sr.status = http.StatusOK
+	}
+	n, err := io.Copy(sr.ResponseWriter, r)
+	sr.bytes += n
+	return n, err
+}
+
+// Flush: Streaming handlers type-assert http.Flusher, so pass it through
+func (sr *statusRecorder) Flush() {
+	if f, ok := sr.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap: Lets http.ResponseController reach the real writer (deadlines, hijacking, ...)
+func (sr *statusRecorder) Unwrap() http.ResponseWriter {
+	return sr.ResponseWriter
+}
+
+// accessLog: Assigns the request ID and emits one record per request after the handler returns
+func accessLog(logger *slog.Logger, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		id := r.Header.Get(requestIDHeader)
+		if !validRequestID(id) {
+			id = rand.Text()
+		}
+		w.Header().Set(requestIDHeader, id)
+		r = r.WithContext(context.WithValue(r.Context(), requestIDKey, id))
+
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+		if rec.status == 0 {
+			rec.status = http.StatusOK // Handler wrote nothing at all
+		}
+
+		attrs := []slog.Attr{
+			slog.String("request_id", id),
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.Int("status", rec.status),
+			slog.Int64("bytes", rec.bytes),
+			slog.Float64("latency_ms", float64(time.Since(start).Microseconds())/1000),
+			slog.String("proto", r.Proto), // "HTTP/2.0" or "HTTP/1.1"
+			slog.String("remote_addr", r.RemoteAddr),
+		}
+		if r.TLS != nil {
+			attrs = append(attrs,
+				slog.String("tls_version", getTLSVersionName(r.TLS.Version)),
+				slog.String("cipher_suite", tls.CipherSuiteName(r.TLS.CipherSuite)),
+				slog.String("alpn", r.TLS.NegotiatedProtocol),
+			)
+		}
+		if cert := verifiedPeer(r); cert != nil {
+			attrs = append(attrs, slog.String("client_cn", cert.Subject.CommonName))
+		}
+
+		logger.LogAttrs(r.Context(), slog.LevelInfo, "request", attrs...)
+	})
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+)
+
+/**
+ * FUTURE REFERENCE: ADMIN LISTENER
+ * --------------------------------
+ * Operational endpoints (metrics, ...) live on their own port so they can be firewalled
+ * separately and scraped by tools that do not hold a client certificate.
+ * By default it listens on localhost in plaintext; with TLS enabled it reuses the
+ * main server's mTLS configuration.
+ */
+
+// newAdminServer: Builds the admin listener. tlsConfig == nil means plaintext.
+func newAdminServer(addr string, tlsConfig *tls.Config, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		TLSConfig:         tlsConfig,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+}
+
+// serveAdmin: Runs the admin listener in the background. A listener that cannot start is fatal.
+func serveAdmin(admin *http.Server) {
+	go func() {
+		var err error
+		if admin.TLSConfig != nil {
+			err = admin.ListenAndServeTLS("", "")
+		} else {
+			err = admin.ListenAndServe()
+		}
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalln("STARTUP ERROR: admin listener failed:", err)
+		}
+	}()
+}
+
+// shutdownAdmin: Nothing on the admin port is worth waiting long for
+func shutdownAdmin(admin *http.Server) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := admin.Shutdown(ctx); err != nil {
+		admin.Close()
+	}
+}
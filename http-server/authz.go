@@ -0,0 +1,183 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+)
+
+/**
+ * FUTURE REFERENCE: AUTHENTICATION vs AUTHORIZATION
+ * -------------------------------------------------
+ * mTLS answers "WHO are you?" (the handshake proves the client owns a cert our CA signed).
+ * The policy below answers "WHAT may you do?" by mapping certificate identities to routes.
+ * Anything not explicitly allowed is denied (default deny).
+ */
+
+// authzPolicy: The parsed policy file
+type authzPolicy struct {
+	Rules []authzRule `json:"rules"`
+}
+
+// authzRule: Grants the identities described by Match access to the Allow routes
+type authzRule struct {
+	Name  string          `json:"name"`
+	Match identityMatcher `json:"match"`
+	Allow []routeGrant    `json:"allow"`
+}
+
+// identityMatcher: Every non-empty field must match the client certificate (logical AND).
+// Any=true matches every verified client; Anonymous=true matches only clients WITHOUT a certificate
+// (possible when the listener runs in per-route client auth mode).
+type identityMatcher struct {
+	Any       bool   `json:"any,omitempty"`
+	Anonymous bool   `json:"anonymous,omitempty"`
+	CN        string `json:"cn,omitempty"`
+	OU        string `json:"ou,omitempty"`
+	DNS       string `json:"dns,omitempty"`
+	URI       string `json:"uri,omitempty"`
+	SPIFFE    string `json:"spiffe,omitempty"`
+}
+
+// routeGrant: A path (exact, or a prefix when it ends in "/*") plus the allowed methods (empty = all)
+type routeGrant struct {
+	Path    string   `json:"path"`
+	Methods []string `json:"methods,omitempty"`
+}
+
+// loadAuthzPolicy: Reads and validates a JSON policy file
+func loadAuthzPolicy(path string) (*authzPolicy, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("read policy %s: %w", path, err)
+	}
+	defer f.Close()
+
+	var policy authzPolicy
+	dec := json.NewDecoder(f)
+	dec.DisallowUnknownFields() // A typo in a security policy should fail loudly
+	if err := dec.Decode(&policy); err != nil {
+		return nil, fmt.Errorf("parse policy %s: %w", path, err)
+	}
+
+	if err := policy.validate(); err != nil {
+		return nil, fmt.Errorf("invalid policy %s: %w", path, err)
+	}
+	return &policy, nil
+}
+
+// validate: Rejects rules that would silently match nothing (or everything by accident)
+func (p *authzPolicy) validate() error {
+	for i := range p.Rules {
+		rule := &p.Rules[i]
+		if rule.Name == "" {
+			rule.Name = fmt.Sprintf("rule-%d", i)
+		}
+		if rule.Match == (identityMatcher{}) {
+			return fmt.Errorf("%s: match is empty (use \"any\": true to match every client)", rule.Name)
+		}
+		if rule.Match.Anonymous && rule.Match != (identityMatcher{Anonymous: true}) {
+			return fmt.Errorf("%s: \"anonymous\" cannot be combined with other match fields", rule.Name)
+		}
+		if len(rule.Allow) == 0 {
+			return fmt.Errorf("%s: allow is empty", rule.Name)
+		}
+		for j, grant := range rule.Allow {
+			if !strings.HasPrefix(grant.Path, "/") {
+				return fmt.Errorf("%s: allow[%d]: path %q must start with /", rule.Name, j, grant.Path)
+			}
+			for k, m := range grant.Methods {
+				rule.Allow[j].Methods[k] = strings.ToUpper(m)
+			}
+		}
+	}
+	if len(p.Rules) == 0 {
+		return errors.New("no rules (every request would be denied)")
+	}
+	return nil
+}
+
+// matches: Checks a client identity (nil for clients without a certificate) against the matcher
+func (m identityMatcher) matches(id *clientIdentity) bool {
+	if m.Anonymous || id == nil {
+		return m.Anonymous && id == nil
+	}
+	if m.Any {
+		return true
+	}
+	if m.CN != "" && m.CN != id.CN {
+		return false
+	}
+	if m.OU != "" && !slices.Contains(id.OUs, m.OU) {
+		return false
+	}
+	if m.DNS != "" && !slices.Contains(id.DNSNames, m.DNS) {
+		return false
+	}
+	if m.URI != "" && !slices.Contains(id.URIs, m.URI) {
+		return false
+	}
+	if m.SPIFFE != "" && m.SPIFFE != id.spiffeID() {
+		return false
+	}
+	return true
+}
+
+// allows: Checks a method + path against the grant
+func (g routeGrant) allows(method, path string) bool {
+	if len(g.Methods) > 0 && !slices.Contains(g.Methods, method) && !slices.Contains(g.Methods, "*") {
+		return false
+	}
+	if prefix, ok := strings.CutSuffix(g.Path, "/*"); ok {
+		return path == prefix || strings.HasPrefix(path, prefix+"/")
+	}
+	return path == g.Path
+}
+
+// authorize: Returns the name of the first rule granting access, or "" when denied
+func (p *authzPolicy) authorize(id *clientIdentity, method, path string) string {
+	for _, rule := range p.Rules {
+		if !rule.Match.matches(id) {
+			continue
+		}
+		for _, grant := range rule.Allow {
+			if grant.allows(method, path) {
+				return rule.Name
+			}
+		}
+	}
+	return ""
+}
+
+// middleware: Rejects requests whose client identity is not granted the route
+func (p *authzPolicy) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var id *clientIdentity // nil: no certificate (only possible in per-route client auth mode)
+		if cert := verifiedPeer(r); cert != nil {
+			identity := identityFromCert(cert)
+			id = &identity
+		}
+
+		if p.authorize(id, r.Method, r.URL.Path) == "" {
+			if id == nil {
+				log.Printf("AUTHZ DENIED: anonymous %s %s", r.Method, r.URL.Path)
+				writeJSONError(w, http.StatusForbidden, "forbidden",
+					"anonymous clients are not allowed to access this route",
+					map[string]any{"method": r.Method, "path": r.URL.Path})
+				return
+			}
+			log.Printf("AUTHZ DENIED: CN=%q %s %s", id.CN, r.Method, r.URL.Path)
+			writeJSONError(w, http.StatusForbidden, "forbidden",
+				"client certificate is not allowed to access this route",
+				map[string]any{"identity": id, "method": r.Method, "path": r.URL.Path})
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
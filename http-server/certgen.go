@@ -0,0 +1,467 @@
+package main
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"flag"
+	"fmt"
+	"math/big"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"software.sslmate.com/src/go-pkcs12" // PKCS#12 (.p12) encoding for browsers, Java and curl
+)
+
+/**
+ * FUTURE REFERENCE: DEV PKI
+ * -------------------------
+ * A real mTLS setup has THREE kinds of certificates:
+ *   1. A root CA (ca.pem) that signs everything below. Both sides trust it.
+ *   2. A server certificate (cert.pem) whose SANs match the hostname clients dial.
+ *   3. Client certificates whose CN / SANs are the identities authz.go reasons about.
+ * `go run . certgen <command>` builds all three without openssl:
+ *
+ *   go run . certgen ca
+ *   go run . certgen server -hosts localhost,127.0.0.1
+ *   go run . certgen client -cn orders-service -ou Operations
+ *   go run . certgen renew -within 720h ca.pem cert.pem client-orders-service.pem
+ * renew also rewrites client-orders-service.p12 if it exists; it then needs -p12-password (the same as before)
+ * and refuses to renew without it rather than guessing a password.
+ */
+
+// runCertgen: Entry point for the `certgen` subcommand
+func runCertgen(args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: certgen <ca|server|client|renew> [flags]")
+	}
+
+	switch args[0] {
+	case "ca":
+		return certgenCA(args[1:])
+	case "server":
+		return certgenLeaf(args[1:], x509.ExtKeyUsageServerAuth)
+	case "client":
+		return certgenLeaf(args[1:], x509.ExtKeyUsageClientAuth)
+	case "renew":
+		return certgenRenew(args[1:])
+	default:
+		return fmt.Errorf("unknown certgen command %q (want ca, server, client or renew)", args[0])
+	}
+}
+
+// certgenCA: Creates a self-signed root CA
+func certgenCA(args []string) error {
+	fs := flag.NewFlagSet("certgen ca", flag.ContinueOnError)
+	outDir := fs.String("out-dir", ".", "directory to write ca.pem and ca-key.pem into")
+	cn := fs.String("cn", "Dev Root CA", "common name of the CA")
+	validity := fs.Duration("validity", 10*365*24*time.Hour, "how long the CA is valid")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return fmt.Errorf("generate CA key: %w", err)
+	}
+
+	template, err := newTemplate(pkix.Name{CommonName: *cn, Organization: []string{"Dev PKI"}}, *validity)
+	if err != nil {
+		return err
+	}
+	template.IsCA = true
+	template.BasicConstraintsValid = true
+	template.MaxPathLenZero = true // This CA signs leaves only, never intermediates
+	template.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageCRLSign
+
+	// Self-signed: the template is its own parent
+	cert, err := signCertificate(template, template, key.Public(), key)
+	if err != nil {
+		return err
+	}
+
+	certPath, keyPath := filepath.Join(*outDir, "ca.pem"), filepath.Join(*outDir, "ca-key.pem")
+	if err := writeKeyPair(certPath, keyPath, []*x509.Certificate{cert}, key); err != nil {
+		return err
+	}
+	fmt.Printf("CA %q written to %s (key: %s), valid until %s\n", *cn, certPath, keyPath, cert.NotAfter.Format(time.DateOnly))
+	return nil
+}
+
+// certgenLeaf: Issues a server (usage=ServerAuth) or client (usage=ClientAuth) certificate signed by the CA
+func certgenLeaf(args []string, usage x509.ExtKeyUsage) error {
+	isServer := usage == x509.ExtKeyUsageServerAuth
+
+	fs := flag.NewFlagSet("certgen", flag.ContinueOnError)
+	outDir := fs.String("out-dir", ".", "directory to write the certificate into")
+	caCert := fs.String("ca", "ca.pem", "CA certificate used for signing")
+	caKey := fs.String("ca-key", "ca-key.pem", "CA private key used for signing")
+	cn := fs.String("cn", "localhost", "common name (for clients this is the identity authz and rate limits use)")
+	ou := fs.String("ou", "", "organizational unit (comma separated)")
+	hosts := fs.String("hosts", "", "DNS names and IP addresses to put in the SAN (comma separated)")
+	uris := fs.String("uris", "", "URI SANs such as SPIFFE IDs (comma separated)")
+	validity := fs.Duration("validity", 365*24*time.Hour, "how long the certificate is valid")
+	name := fs.String("name", "", "output file name without extension (default cert for servers, client-<cn> for clients)")
+	writeP12 := fs.Bool("p12", !isServer, "also write a PKCS#12 bundle (key + certificate + CA)")
+	p12Password := fs.String("p12-password", "changeit", "password protecting the PKCS#12 bundle")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	ca, caPriv, err := loadCA(*caCert, *caKey)
+	if err != nil {
+		return err
+	}
+
+	subject := pkix.Name{CommonName: *cn, OrganizationalUnit: splitList(*ou)}
+	template, err := newTemplate(subject, *validity)
+	if err != nil {
+		return err
+	}
+	template.KeyUsage = x509.KeyUsageDigitalSignature
+	template.ExtKeyUsage = []x509.ExtKeyUsage{usage}
+
+	for _, h := range splitList(*hosts) {
+		if ip := net.ParseIP(h); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, h)
+		}
+	}
+	if isServer && len(template.DNSNames) == 0 && len(template.IPAddresses) == 0 {
+		// Clients ignore the CN for hostname checks; without SANs no client would accept the cert
+		template.DNSNames = []string{*cn}
+	}
+	for _, raw := range splitList(*uris) {
+		u, err := url.Parse(raw)
+		if err != nil {
+			return fmt.Errorf("invalid URI SAN %q: %w", raw, err)
+		}
+		template.URIs = append(template.URIs, u)
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return fmt.Errorf("generate key: %w", err)
+	}
+	cert, err := signCertificate(template, ca, key.Public(), caPriv)
+	if err != nil {
+		return err
+	}
+
+	base := *name
+	if base == "" {
+		base = "client-" + *cn
+		if isServer {
+			base = "cert"
+		}
+	}
+	certPath := filepath.Join(*outDir, base+".pem")
+	keyPath := keyPathFor(certPath)
+
+	// The chain (leaf + CA) lets peers and the OCSP stapler find the issuer
+	if err := writeKeyPair(certPath, keyPath, []*x509.Certificate{cert, ca}, key); err != nil {
+		return err
+	}
+	fmt.Printf("Certificate %q written to %s (key: %s), valid until %s\n", *cn, certPath, keyPath, cert.NotAfter.Format(time.DateOnly))
+
+	if *writeP12 {
+		p12Path := filepath.Join(*outDir, base+".p12")
+		if err := writePKCS12(p12Path, key, cert, ca, *p12Password); err != nil {
+			return err
+		}
+		fmt.Printf("PKCS#12 bundle written to %s\n", p12Path)
+	}
+	return nil
+}
+
+// certgenRenew: Re-issues every given certificate that expires within the threshold.
+// Leaves get a fresh key; the CA keeps its key so already issued certificates still chain to it.
+func certgenRenew(args []string) error {
+	fs := flag.NewFlagSet("certgen renew", flag.ContinueOnError)
+	caCert := fs.String("ca", "ca.pem", "CA certificate used for signing")
+	caKey := fs.String("ca-key", "ca-key.pem", "CA private key used for signing")
+	within := fs.Duration("within", 30*24*time.Hour, "renew certificates expiring within this window")
+	p12Password := fs.String("p12-password", "", "password for the PKCS#12 bundle rewritten next to a renewed certificate (required if one exists)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return errors.New("usage: certgen renew [flags] <cert.pem>...")
+	}
+
+	for _, certPath := range fs.Args() {
+		chain, err := readCertificates(certPath)
+		if err != nil {
+			return err
+		}
+		old := chain[0]
+
+		remaining := time.Until(old.NotAfter)
+		if remaining > *within {
+			fmt.Printf("%s: valid until %s, nothing to do\n", certPath, old.NotAfter.Format(time.DateOnly))
+			continue
+		}
+
+		renewed, err := renewCertificate(certPath, old, *caCert, *caKey, *p12Password)
+		if err != nil {
+			return fmt.Errorf("renew %s: %w", certPath, err)
+		}
+		fmt.Printf("%s: renewed, valid until %s\n", certPath, renewed.NotAfter.Format(time.DateOnly))
+	}
+	return nil
+}
+
+// renewCertificate: Copies identity and usage from old into a new certificate with the same lifetime.
+// A PKCS#12 bundle next to the certificate (X.pem -> X.p12) is rewritten too, or it would keep the old one;
+// that needs its password, so a missing p12Password is an error before anything is written.
+func renewCertificate(certPath string, old *x509.Certificate, caCertPath, caKeyPath, p12Password string) (*x509.Certificate, error) {
+	template, err := newTemplate(old.Subject, old.NotAfter.Sub(old.NotBefore))
+	if err != nil {
+		return nil, err
+	}
+	template.DNSNames = old.DNSNames
+	template.IPAddresses = old.IPAddresses
+	template.URIs = old.URIs
+	template.EmailAddresses = old.EmailAddresses
+	template.KeyUsage = old.KeyUsage
+	template.ExtKeyUsage = old.ExtKeyUsage
+	template.IsCA = old.IsCA
+	template.BasicConstraintsValid = old.BasicConstraintsValid
+	template.MaxPathLen = old.MaxPathLen
+	template.MaxPathLenZero = old.MaxPathLenZero
+
+	keyPath := keyPathFor(certPath)
+
+	// Renewing the CA itself: re-sign with its existing key
+	if old.IsCA && old.CheckSignatureFrom(old) == nil {
+		key, err := readPrivateKey(keyPath)
+		if err != nil {
+			return nil, err
+		}
+		template.SubjectKeyId = old.SubjectKeyId
+		cert, err := signCertificate(template, template, key.Public(), key)
+		if err != nil {
+			return nil, err
+		}
+		return cert, writeKeyPair(certPath, keyPath, []*x509.Certificate{cert}, key)
+	}
+
+	// Checked before anything is written, so a missing password does not leave the bundle behind the PEM files
+	p12Path := strings.TrimSuffix(certPath, ".pem") + ".p12"
+	_, statErr := os.Stat(p12Path)
+	hasP12 := statErr == nil
+	if hasP12 && p12Password == "" {
+		return nil, fmt.Errorf("%s exists: pass -p12-password with its password so it can be rewritten", p12Path)
+	}
+
+	ca, caPriv, err := loadCA(caCertPath, caKeyPath)
+	if err != nil {
+		return nil, err
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("generate key: %w", err)
+	}
+	cert, err := signCertificate(template, ca, key.Public(), caPriv)
+	if err != nil {
+		return nil, err
+	}
+	if err := writeKeyPair(certPath, keyPath, []*x509.Certificate{cert, ca}, key); err != nil {
+		return nil, err
+	}
+
+	if hasP12 {
+		if err := writePKCS12(p12Path, key, cert, ca, p12Password); err != nil {
+			return nil, err
+		}
+		fmt.Printf("%s: PKCS#12 bundle rewritten\n", p12Path)
+	}
+	return cert, nil
+}
+
+// newTemplate: The fields every certificate we issue shares
+func newTemplate(subject pkix.Name, validity time.Duration) (*x509.Certificate, error) {
+	// 128 random bits: serials must be unique per CA and should be unpredictable
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, fmt.Errorf("generate serial: %w", err)
+	}
+
+	now := time.Now()
+	return &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      subject,
+		NotBefore:    now.Add(-5 * time.Minute), // Tolerate small clock skew between machines
+		NotAfter:     now.Add(validity),
+	}, nil
+}
+
+// signCertificate: Signs template with parent's key and parses the result back
+func signCertificate(template, parent *x509.Certificate, pub crypto.PublicKey, signer crypto.Signer) (*x509.Certificate, error) {
+	if template.IsCA && template.SubjectKeyId == nil {
+		// Self-signed CAs need an explicit SubjectKeyId so leaves can reference it
+		spki, err := x509.MarshalPKIXPublicKey(pub)
+		if err != nil {
+			return nil, fmt.Errorf("marshal public key: %w", err)
+		}
+		sum := sha1.Sum(spki)
+		template.SubjectKeyId = sum[:]
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, pub, signer)
+	if err != nil {
+		return nil, fmt.Errorf("sign certificate %q: %w", template.Subject.CommonName, err)
+	}
+	return x509.ParseCertificate(der)
+}
+
+// loadCA: Reads the signing CA certificate and key
+func loadCA(certPath, keyPath string) (*x509.Certificate, crypto.Signer, error) {
+	certs, err := readCertificates(certPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w (run `go run . certgen ca` first)", err)
+	}
+	if !certs[0].IsCA {
+		return nil, nil, fmt.Errorf("%s is not a CA certificate", certPath)
+	}
+	key, err := readPrivateKey(keyPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	return certs[0], key, nil
+}
+
+// readCertificates: Parses every CERTIFICATE block of a PEM file (leaf first)
+func readCertificates(path string) ([]*x509.Certificate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", path, err)
+	}
+
+	var certs []*x509.Certificate
+	for block, rest := pem.Decode(data); block != nil; block, rest = pem.Decode(rest) {
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("parse %s: %w", path, err)
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("%s contains no PEM certificate", path)
+	}
+	return certs, nil
+}
+
+// readPrivateKey: Parses a PKCS#8, EC or PKCS#1 PEM private key
+func readPrivateKey(path string) (crypto.Signer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", path, err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s contains no PEM block", path)
+	}
+
+	var key any
+	switch block.Type {
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("%s: unsupported key type %T", path, key)
+	}
+	return signer, nil
+}
+
+// writeKeyPair: Writes the chain and the PKCS#8 key as PEM.
+// The key is written first so the hot reloader never sees a new certificate with an old key for long.
+func writeKeyPair(certPath, keyPath string, chain []*x509.Certificate, key crypto.Signer) error {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return fmt.Errorf("marshal key: %w", err)
+	}
+	if err := writeFileAtomic(keyPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+		return err
+	}
+
+	var certPEM []byte
+	for _, cert := range chain {
+		certPEM = append(certPEM, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})...)
+	}
+	return writeFileAtomic(certPath, certPEM, 0o644)
+}
+
+// writePKCS12: Bundles key, certificate and CA into a password protected PKCS#12 file
+func writePKCS12(path string, key crypto.Signer, cert, ca *x509.Certificate, password string) error {
+	pfx, err := pkcs12.Modern.Encode(key, cert, []*x509.Certificate{ca}, password)
+	if err != nil {
+		return fmt.Errorf("encode PKCS#12: %w", err)
+	}
+	return writeFileAtomic(path, pfx, 0o600)
+}
+
+// writeFileAtomic: Write to a temp file and rename, so readers never see a half written file
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("write %s: %w", path, err)
+	}
+	defer os.Remove(tmp.Name()) // No-op after a successful rename
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("write %s: %w", path, err)
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return fmt.Errorf("chmod %s: %w", path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("write %s: %w", path, err)
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// keyPathFor: cert.pem -> key.pem (the server default), anything else X.pem -> X-key.pem
+func keyPathFor(certPath string) string {
+	dir, base := filepath.Split(certPath)
+	if base == "cert.pem" {
+		return filepath.Join(dir, "key.pem")
+	}
+	return filepath.Join(dir, strings.TrimSuffix(base, ".pem")+"-key.pem")
+}
+
+// splitList: Splits a comma separated flag value, dropping empty entries
+func splitList(s string) []string {
+	var out []string
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
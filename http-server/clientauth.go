@@ -0,0 +1,81 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+)
+
+/**
+ * FUTURE REFERENCE: PER-ROUTE CLIENT AUTH
+ * ---------------------------------------
+ * tls.RequireAndVerifyClientCert is all-or-nothing: the handshake fails before we even
+ * know which URL the client wants, so a load balancer health check needs a certificate too.
+ * With tls.VerifyClientCertIfGiven the handshake accepts clients without a certificate
+ * (a certificate that IS sent must still verify) and each route decides for itself:
+ *   - required:  401 unless a verified client certificate was presented (e.g. /orders)
+ *   - optional:  anyone may call it (e.g. "/", health checks)
+ *   - forbidden: 403 if a certificate was presented (public endpoints that must not see identities)
+ */
+
+// Client auth modes for the TLS listener
+const (
+	clientAuthRequire  = "require"   // Every handshake needs a verified client certificate (default)
+	clientAuthPerRoute = "per-route" // Certificates are verified if given; routes enforce their own requirement
+)
+
+// tlsClientAuth: Maps the configured mode to the crypto/tls setting
+func tlsClientAuth(mode string) (tls.ClientAuthType, error) {
+	switch mode {
+	case "", clientAuthRequire:
+		return tls.RequireAndVerifyClientCert, nil
+	case clientAuthPerRoute:
+		return tls.VerifyClientCertIfGiven, nil
+	default:
+		return 0, fmt.Errorf("unknown client auth mode %q (want %s or %s)", mode, clientAuthRequire, clientAuthPerRoute)
+	}
+}
+
+// certRequirement: What a route expects from the client certificate
+type certRequirement int
+
+const (
+	certRequired certRequirement = iota
+	certOptional
+	certForbidden
+)
+
+func (req certRequirement) String() string {
+	switch req {
+	case certRequired:
+		return "required"
+	case certOptional:
+		return "optional"
+	case certForbidden:
+		return "forbidden"
+	default:
+		return "unknown"
+	}
+}
+
+// requireCert: Middleware enforcing req, meant for a router group (see router.go)
+func requireCert(req certRequirement) middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			hasCert := verifiedPeer(r) != nil
+
+			switch {
+			case req == certRequired && !hasCert:
+				writeJSONError(w, http.StatusUnauthorized, "client_certificate_required",
+					"this route requires a verified client certificate", map[string]string{"path": r.URL.Path})
+				return
+			case req == certForbidden && hasCert:
+				writeJSONError(w, http.StatusForbidden, "client_certificate_not_allowed",
+					"this route must be called without a client certificate", map[string]string{"path": r.URL.Path})
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,471 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"time"
+)
+
+/**
+ * FUTURE REFERENCE: CONFIGURATION LAYERS
+ * --------------------------------------
+ * Every setting is resolved the same way flo_go/fallback/main.go resolves its port:
+ *   cmp.Or(flag, env, file, default)  ->  the first non-zero value wins
+ * so a JSON file (--config / CONFIG_FILE, see config.example.json) holds the baseline,
+ * env vars override it per deployment and flags override everything for a single run.
+ * The catch of cmp.Or: a zero value means "not set", so a higher layer cannot set a
+ * string to "" or a timeout to 0. Booleans are *bool for the same reason (nil = not set).
+ * `go run . --print-config` shows the merged result without starting anything.
+ */
+
+// duration: time.Duration that reads and writes "30s" in JSON instead of nanoseconds
+type duration struct{ time.Duration }
+
+func (d duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration must be a string like \"30s\": %w", err)
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	d.Duration = parsed
+	return nil
+}
+
+// serverConfig: Everything main needs to build the listeners
+type serverConfig struct {
+	Listen         listenConfig     `json:"listen"`
+	TLS            tlsConfigFile    `json:"tls"`
+	Revocation     revocationConfig `json:"revocation"`
+	Timeouts       timeoutConfig    `json:"timeouts"`
+	MaxHeaderBytes int              `json:"max_header_bytes"`
+	Limits         limitsConfig     `json:"limits"`
+	HTTP2          http2Config      `json:"http2"`
+	Admin          adminConfig      `json:"admin"`
+	Expiry         expiryConfig     `json:"expiry"`
+
+	LogFormat       string `json:"log_format"`        // json or logfmt
+	OrdersFile      string `json:"orders_file"`       // Empty = orders are kept in memory
+	AuthzPolicyFile string `json:"authz_policy_file"` // Empty = every verified client may call every route
+	RateLimitFile   string `json:"rate_limit_file"`   // Empty = no rate limits
+	RabbitMQAddr    string `json:"rabbitmq_addr"`     // Empty = no readiness check for RabbitMQ
+}
+
+type listenConfig struct {
+	Addr                string   `json:"addr"`
+	Mode                string   `json:"mode"`       // tls or h2c (see proxy.go)
+	HTTP3Addr           string   `json:"http3_addr"` // Empty = no QUIC listener
+	TrustedProxies      []string `json:"trusted_proxies"`
+	ForwardedCertHeader string   `json:"forwarded_cert_header"`
+}
+
+// tlsConfigFile: File paths and client auth, plus the profile settings from tlsprofile.go
+type tlsConfigFile struct {
+	CertFile     string `json:"cert_file"`
+	KeyFile      string `json:"key_file"`
+	ClientCAFile string `json:"client_ca_file"` // Empty = ca.pem, or cert_file when ca.pem does not exist
+	ClientAuth   string `json:"client_auth"`    // require or per-route (see clientauth.go)
+	tlsSettings
+}
+
+type revocationConfig struct {
+	CRLFiles      []string `json:"crl_files"`
+	OCSPMode      string   `json:"ocsp_mode"`      // off, soft or hard
+	OCSPResponder string   `json:"ocsp_responder"` // Empty = responder URL from the certificate
+	OCSPStaple    *bool    `json:"ocsp_staple"`
+}
+
+type timeoutConfig struct {
+	ReadHeader duration `json:"read_header"`
+	Read       duration `json:"read"`
+	Write      duration `json:"write"`
+	Idle       duration `json:"idle"`
+	Shutdown   duration `json:"shutdown"`    // How long in-flight requests get to finish on SIGINT/SIGTERM
+	DrainDelay duration `json:"drain_delay"` // How long /readyz reports 503 before listeners close (0 = close at once)
+}
+
+// limitsConfig: Per-request limits (see limits.go)
+type limitsConfig struct {
+	MaxBodyBytes   int64               `json:"max_body_bytes"`
+	HandlerTimeout duration            `json:"handler_timeout"` // Always on: responses are buffered, so handlers cannot Flush
+	RouteTimeouts  map[string]duration `json:"route_timeouts"`  // Router pattern ("GET /orders/{id}") -> timeout
+}
+
+type http2Config struct {
+	MaxConcurrentStreams uint32   `json:"max_concurrent_streams"` // 0 = x/net default (250)
+	MaxReadFrameSize     uint32   `json:"max_read_frame_size"`    // 0 = x/net default (1 MiB)
+	IdleTimeout          duration `json:"idle_timeout"`           // 0 = timeouts.idle
+}
+
+type expiryConfig struct {
+	WarnBefore    duration `json:"warn_before"`    // Certificates expiring within this window are reported
+	CheckInterval duration `json:"check_interval"` // How often the server certificate and CAs are re-checked
+}
+
+type adminConfig struct {
+	Addr string `json:"addr"`
+	TLS  *bool  `json:"tls"` // Require mTLS on the admin listener too
+}
+
+// defaultConfig: The lowest layer; matches what the server did before it had a config file
+func defaultConfig() serverConfig {
+	off := false
+	return serverConfig{
+		Listen: listenConfig{
+			Addr:                ":3000",
+			Mode:                listenTLS,
+			TrustedProxies:      []string{},
+			ForwardedCertHeader: defaultForwardedCertHeader,
+		},
+		TLS: tlsConfigFile{
+			CertFile:    "cert.pem",
+			KeyFile:     "key.pem",
+			ClientAuth:  clientAuthRequire,
+			tlsSettings: tlsSettings{Profile: "intermediate"},
+		},
+		Revocation: revocationConfig{CRLFiles: []string{}, OCSPMode: ocspOff, OCSPStaple: &off},
+		// Slowloris protection: a client gets 5s for its headers and 30s for the whole request
+		Timeouts: timeoutConfig{
+			ReadHeader: duration{5 * time.Second},
+			Read:       duration{30 * time.Second},
+			Write:      duration{60 * time.Second}, // Longer than the handler timeout, or slow handlers lose their 503
+			Idle:       duration{2 * time.Minute},
+			Shutdown:   duration{30 * time.Second},
+		},
+		MaxHeaderBytes: 64 << 10,
+		Limits: limitsConfig{
+			MaxBodyBytes:   1 << 20,
+			HandlerTimeout: duration{10 * time.Second},
+			RouteTimeouts:  map[string]duration{},
+		},
+		Admin:     adminConfig{Addr: "localhost:9090", TLS: &off},
+		Expiry:    expiryConfig{WarnBefore: duration{30 * 24 * time.Hour}, CheckInterval: duration{time.Hour}},
+		LogFormat: "json",
+	}
+}
+
+// loadConfig: Merges defaults < file < env < flags. printOnly reports --print-config.
+func loadConfig(args []string) (cfg serverConfig, printOnly bool, err error) {
+	flags, configFile, printOnly, err := parseConfigFlags(args)
+	if err != nil {
+		return cfg, false, err
+	}
+	env, err := configFromEnv()
+	if err != nil {
+		return cfg, false, err
+	}
+
+	var file serverConfig
+	if path := cmp.Or(configFile, os.Getenv("CONFIG_FILE")); path != "" {
+		if file, err = loadConfigFile(path); err != nil {
+			return cfg, false, err
+		}
+	}
+
+	cfg = defaultConfig().overlay(file).overlay(env).overlay(flags)
+	return cfg, printOnly, cfg.validate()
+}
+
+// loadConfigFile: Strict like the policy files, so a misspelt key is an error instead of silently ignored
+func loadConfigFile(path string) (serverConfig, error) {
+	var cfg serverConfig
+
+	f, err := os.Open(path)
+	if err != nil {
+		return cfg, fmt.Errorf("open config: %w", err)
+	}
+	defer f.Close()
+
+	dec := json.NewDecoder(f)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&cfg); err != nil {
+		return cfg, fmt.Errorf("parse config %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// overlay: Returns c with every field that is set in top replaced by top's value
+func (c serverConfig) overlay(top serverConfig) serverConfig {
+	c.Listen.Addr = cmp.Or(top.Listen.Addr, c.Listen.Addr)
+	c.Listen.Mode = cmp.Or(top.Listen.Mode, c.Listen.Mode)
+	c.Listen.HTTP3Addr = cmp.Or(top.Listen.HTTP3Addr, c.Listen.HTTP3Addr)
+	c.Listen.TrustedProxies = orList(top.Listen.TrustedProxies, c.Listen.TrustedProxies)
+	c.Listen.ForwardedCertHeader = cmp.Or(top.Listen.ForwardedCertHeader, c.Listen.ForwardedCertHeader)
+
+	c.TLS.CertFile = cmp.Or(top.TLS.CertFile, c.TLS.CertFile)
+	c.TLS.KeyFile = cmp.Or(top.TLS.KeyFile, c.TLS.KeyFile)
+	c.TLS.ClientCAFile = cmp.Or(top.TLS.ClientCAFile, c.TLS.ClientCAFile)
+	c.TLS.ClientAuth = cmp.Or(top.TLS.ClientAuth, c.TLS.ClientAuth)
+	c.TLS.Profile = cmp.Or(top.TLS.Profile, c.TLS.Profile)
+	c.TLS.MinVersion = cmp.Or(top.TLS.MinVersion, c.TLS.MinVersion)
+	c.TLS.MaxVersion = cmp.Or(top.TLS.MaxVersion, c.TLS.MaxVersion)
+	c.TLS.CipherSuites = orList(top.TLS.CipherSuites, c.TLS.CipherSuites)
+	c.TLS.Curves = orList(top.TLS.Curves, c.TLS.Curves)
+	c.TLS.ALPN = orList(top.TLS.ALPN, c.TLS.ALPN)
+
+	c.Revocation.CRLFiles = orList(top.Revocation.CRLFiles, c.Revocation.CRLFiles)
+	c.Revocation.OCSPMode = cmp.Or(top.Revocation.OCSPMode, c.Revocation.OCSPMode)
+	c.Revocation.OCSPResponder = cmp.Or(top.Revocation.OCSPResponder, c.Revocation.OCSPResponder)
+	c.Revocation.OCSPStaple = cmp.Or(top.Revocation.OCSPStaple, c.Revocation.OCSPStaple)
+
+	c.Timeouts.ReadHeader = cmp.Or(top.Timeouts.ReadHeader, c.Timeouts.ReadHeader)
+	c.Timeouts.Read = cmp.Or(top.Timeouts.Read, c.Timeouts.Read)
+	c.Timeouts.Write = cmp.Or(top.Timeouts.Write, c.Timeouts.Write)
+	c.Timeouts.Idle = cmp.Or(top.Timeouts.Idle, c.Timeouts.Idle)
+	c.Timeouts.Shutdown = cmp.Or(top.Timeouts.Shutdown, c.Timeouts.Shutdown)
+	c.Timeouts.DrainDelay = cmp.Or(top.Timeouts.DrainDelay, c.Timeouts.DrainDelay)
+	c.MaxHeaderBytes = cmp.Or(top.MaxHeaderBytes, c.MaxHeaderBytes)
+	c.Limits.MaxBodyBytes = cmp.Or(top.Limits.MaxBodyBytes, c.Limits.MaxBodyBytes)
+	c.Limits.HandlerTimeout = cmp.Or(top.Limits.HandlerTimeout, c.Limits.HandlerTimeout)
+	if len(top.Limits.RouteTimeouts) > 0 {
+		c.Limits.RouteTimeouts = top.Limits.RouteTimeouts
+	}
+
+	c.HTTP2.MaxConcurrentStreams = cmp.Or(top.HTTP2.MaxConcurrentStreams, c.HTTP2.MaxConcurrentStreams)
+	c.HTTP2.MaxReadFrameSize = cmp.Or(top.HTTP2.MaxReadFrameSize, c.HTTP2.MaxReadFrameSize)
+	c.HTTP2.IdleTimeout = cmp.Or(top.HTTP2.IdleTimeout, c.HTTP2.IdleTimeout)
+
+	c.Admin.Addr = cmp.Or(top.Admin.Addr, c.Admin.Addr)
+	c.Admin.TLS = cmp.Or(top.Admin.TLS, c.Admin.TLS)
+
+	c.Expiry.WarnBefore = cmp.Or(top.Expiry.WarnBefore, c.Expiry.WarnBefore)
+	c.Expiry.CheckInterval = cmp.Or(top.Expiry.CheckInterval, c.Expiry.CheckInterval)
+
+	c.LogFormat = cmp.Or(top.LogFormat, c.LogFormat)
+	c.OrdersFile = cmp.Or(top.OrdersFile, c.OrdersFile)
+	c.AuthzPolicyFile = cmp.Or(top.AuthzPolicyFile, c.AuthzPolicyFile)
+	c.RateLimitFile = cmp.Or(top.RateLimitFile, c.RateLimitFile)
+	c.RabbitMQAddr = cmp.Or(top.RabbitMQAddr, c.RabbitMQAddr)
+	return c
+}
+
+// orList: cmp.Or for slices (which are not comparable): the first non-empty list, else the last one
+func orList(lists ...[]string) []string {
+	for _, list := range lists {
+		if len(list) > 0 {
+			return list
+		}
+	}
+	return lists[len(lists)-1]
+}
+
+// validate: Catches the mistakes that would otherwise only show up deep inside main.
+// TLS profile and client auth values are validated where they are turned into tls.Config fields.
+func (c serverConfig) validate() error {
+	if c.Listen.Mode != listenTLS && c.Listen.Mode != listenH2C {
+		return fmt.Errorf("unknown listen mode %q (want %s or %s)", c.Listen.Mode, listenTLS, listenH2C)
+	}
+	if c.Listen.Mode == listenH2C && c.Listen.HTTP3Addr != "" {
+		return errors.New("the HTTP/3 listener needs TLS, it cannot be combined with listen mode h2c")
+	}
+	if _, _, err := net.SplitHostPort(c.Listen.Addr); err != nil {
+		return fmt.Errorf("listen address %q: %w", c.Listen.Addr, err)
+	}
+	if c.LogFormat != "json" && c.LogFormat != "logfmt" {
+		return fmt.Errorf("unknown log format %q (want json or logfmt)", c.LogFormat)
+	}
+	if c.Expiry.CheckInterval.Duration <= 0 {
+		return errors.New("expiry check_interval must be positive")
+	}
+	if c.MaxHeaderBytes < 0 {
+		return errors.New("max_header_bytes cannot be negative")
+	}
+	if c.Timeouts.DrainDelay.Duration < 0 {
+		return errors.New("timeouts drain_delay cannot be negative")
+	}
+	if c.Limits.MaxBodyBytes <= 0 {
+		return errors.New("limits max_body_bytes must be positive")
+	}
+	if c.Limits.HandlerTimeout.Duration <= 0 {
+		return errors.New("limits handler_timeout must be positive")
+	}
+	for route, d := range c.Limits.RouteTimeouts {
+		if d.Duration <= 0 {
+			return fmt.Errorf("limits route_timeouts %q must be positive", route)
+		}
+	}
+	return nil
+}
+
+// configFromEnv: The env layer. Unset variables stay zero so the file or default shows through.
+func configFromEnv() (serverConfig, error) {
+	var env envReader
+	cfg := serverConfig{
+		Listen: listenConfig{
+			Addr:                os.Getenv("LISTEN_ADDR"),
+			Mode:                os.Getenv("LISTEN_MODE"),
+			HTTP3Addr:           os.Getenv("HTTP3_ADDR"),
+			TrustedProxies:      splitList(os.Getenv("TRUSTED_PROXIES")),
+			ForwardedCertHeader: os.Getenv("FORWARDED_CERT_HEADER"),
+		},
+		TLS: tlsConfigFile{
+			CertFile:     os.Getenv("CERT_FILE"),
+			KeyFile:      os.Getenv("KEY_FILE"),
+			ClientCAFile: os.Getenv("CLIENT_CA_FILE"),
+			ClientAuth:   os.Getenv("CLIENT_AUTH_MODE"),
+			tlsSettings: tlsSettings{
+				Profile:      os.Getenv("TLS_PROFILE"),
+				MinVersion:   os.Getenv("TLS_MIN_VERSION"),
+				MaxVersion:   os.Getenv("TLS_MAX_VERSION"),
+				CipherSuites: splitList(os.Getenv("TLS_CIPHER_SUITES")),
+				Curves:       splitList(os.Getenv("TLS_CURVES")),
+				ALPN:         splitList(os.Getenv("TLS_ALPN")),
+			},
+		},
+		Revocation: revocationConfig{
+			CRLFiles:      splitList(os.Getenv("CRL_FILES")),
+			OCSPMode:      os.Getenv("OCSP_MODE"),
+			OCSPResponder: os.Getenv("OCSP_RESPONDER"),
+			OCSPStaple:    env.bool("OCSP_STAPLE"),
+		},
+		Timeouts: timeoutConfig{
+			ReadHeader: env.duration("READ_HEADER_TIMEOUT"),
+			Read:       env.duration("READ_TIMEOUT"),
+			Write:      env.duration("WRITE_TIMEOUT"),
+			Idle:       env.duration("IDLE_TIMEOUT"),
+			Shutdown:   env.duration("SHUTDOWN_TIMEOUT"),
+			DrainDelay: env.duration("DRAIN_DELAY"),
+		},
+		MaxHeaderBytes: int(env.uint("MAX_HEADER_BYTES", 31)),
+		Limits: limitsConfig{
+			MaxBodyBytes:   int64(env.uint("MAX_BODY_BYTES", 63)),
+			HandlerTimeout: env.duration("HANDLER_TIMEOUT"),
+		},
+		HTTP2: http2Config{
+			MaxConcurrentStreams: uint32(env.uint("HTTP2_MAX_CONCURRENT_STREAMS", 32)),
+			MaxReadFrameSize:     uint32(env.uint("HTTP2_MAX_READ_FRAME_SIZE", 32)),
+			IdleTimeout:          env.duration("HTTP2_IDLE_TIMEOUT"),
+		},
+		Admin: adminConfig{
+			Addr: os.Getenv("ADMIN_ADDR"),
+			TLS:  env.bool("ADMIN_TLS"),
+		},
+		Expiry: expiryConfig{
+			WarnBefore:    env.duration("CERT_EXPIRY_WARN_BEFORE"),
+			CheckInterval: env.duration("CERT_EXPIRY_CHECK_INTERVAL"),
+		},
+		LogFormat:       os.Getenv("LOG_FORMAT"),
+		OrdersFile:      os.Getenv("ORDERS_FILE"),
+		AuthzPolicyFile: os.Getenv("AUTHZ_POLICY_FILE"),
+		RateLimitFile:   os.Getenv("RATE_LIMIT_FILE"),
+		RabbitMQAddr:    os.Getenv("RABBITMQ_ADDR"),
+	}
+	return cfg, errors.Join(env.errs...)
+}
+
+// envReader: Parses typed env vars and collects every error, so one run reports all typos
+type envReader struct {
+	errs []error
+}
+
+func (e *envReader) duration(name string) duration {
+	value := os.Getenv(name)
+	if value == "" {
+		return duration{}
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		e.errs = append(e.errs, fmt.Errorf("invalid %s: %w", name, err))
+	}
+	return duration{d}
+}
+
+func (e *envReader) uint(name string, bits int) uint64 {
+	value := os.Getenv(name)
+	if value == "" {
+		return 0
+	}
+	n, err := strconv.ParseUint(value, 10, bits)
+	if err != nil {
+		e.errs = append(e.errs, fmt.Errorf("invalid %s: %w", name, err))
+	}
+	return n
+}
+
+// bool: nil when unset, so OCSP_STAPLE=false can switch off what the file switched on
+func (e *envReader) bool(name string) *bool {
+	value := os.Getenv(name)
+	if value == "" {
+		return nil
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		e.errs = append(e.errs, fmt.Errorf("invalid %s: %w", name, err))
+		return nil
+	}
+	return &b
+}
+
+// parseConfigFlags: The top layer. Only the settings people change for a single run have a flag.
+func parseConfigFlags(args []string) (cfg serverConfig, configFile string, printOnly bool, err error) {
+	fs := flag.NewFlagSet("server", flag.ContinueOnError)
+	fs.StringVar(&configFile, "config", "", "JSON config file (env: CONFIG_FILE), see config.example.json")
+	fs.BoolVar(&printOnly, "print-config", false, "print the merged configuration as JSON and exit")
+	fs.StringVar(&cfg.Listen.Addr, "addr", "", "listen address (env: LISTEN_ADDR, default :3000)")
+	fs.StringVar(&cfg.Listen.Mode, "listen-mode", "", "tls or h2c (env: LISTEN_MODE)")
+	fs.StringVar(&cfg.Listen.HTTP3Addr, "http3-addr", "", "UDP address for HTTP/3 (env: HTTP3_ADDR)")
+	fs.StringVar(&cfg.TLS.CertFile, "cert", "", "server certificate (env: CERT_FILE)")
+	fs.StringVar(&cfg.TLS.KeyFile, "key", "", "server private key (env: KEY_FILE)")
+	fs.StringVar(&cfg.TLS.ClientCAFile, "client-ca", "", "CA bundle for client certificates (env: CLIENT_CA_FILE)")
+	fs.StringVar(&cfg.TLS.ClientAuth, "client-auth", "", "require or per-route (env: CLIENT_AUTH_MODE)")
+	fs.StringVar(&cfg.TLS.Profile, "tls-profile", "", "modern, intermediate or custom (env: TLS_PROFILE)")
+	fs.StringVar(&cfg.Admin.Addr, "admin-addr", "", "admin listener address (env: ADMIN_ADDR)")
+	fs.StringVar(&cfg.LogFormat, "log-format", "", "json or logfmt (env: LOG_FORMAT)")
+	fs.IntVar(&cfg.MaxHeaderBytes, "max-header-bytes", 0, "request header limit in bytes (env: MAX_HEADER_BYTES)")
+	fs.Int64Var(&cfg.Limits.MaxBodyBytes, "max-body-bytes", 0, "request body limit in bytes (env: MAX_BODY_BYTES)")
+	fs.DurationVar(&cfg.Limits.HandlerTimeout.Duration, "handler-timeout", 0, "default handler deadline (env: HANDLER_TIMEOUT)")
+	fs.DurationVar(&cfg.Timeouts.ReadHeader.Duration, "read-header-timeout", 0, "env: READ_HEADER_TIMEOUT")
+	fs.DurationVar(&cfg.Timeouts.Read.Duration, "read-timeout", 0, "env: READ_TIMEOUT")
+	fs.DurationVar(&cfg.Timeouts.Write.Duration, "write-timeout", 0, "env: WRITE_TIMEOUT")
+	fs.DurationVar(&cfg.Timeouts.Idle.Duration, "idle-timeout", 0, "env: IDLE_TIMEOUT")
+	fs.DurationVar(&cfg.Timeouts.Shutdown.Duration, "shutdown-timeout", 0, "env: SHUTDOWN_TIMEOUT")
+	fs.DurationVar(&cfg.Timeouts.DrainDelay.Duration, "drain-delay", 0, "time /readyz reports 503 before listeners close (env: DRAIN_DELAY)")
+
+	if err := fs.Parse(args); err != nil {
+		return cfg, "", false, err
+	}
+	if fs.NArg() > 0 {
+		return cfg, "", false, fmt.Errorf("unexpected argument %q (subcommands: certgen)", fs.Arg(0))
+	}
+	return cfg, configFile, printOnly, nil
+}
+
+// printConfig: --print-config output, in the same format the config file uses
+func printConfig(cfg serverConfig) error {
+	out, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(out))
+	return nil
+}
+
+// displayAddr: ":3000" -> "localhost:3000" for the startup banner
+func displayAddr(addr string) string {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil || host != "" {
+		return addr
+	}
+	return net.JoinHostPort("localhost", port)
+}
+
+// durations: Unwraps a map of config durations for code that takes time.Duration
+func durations(m map[string]duration) map[string]time.Duration {
+	out := make(map[string]time.Duration, len(m))
+	for k, d := range m {
+		out[k] = d.Duration
+	}
+	return out
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"crypto/x509"
+	"log"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+)
+
+/**
+ * FUTURE REFERENCE: CERTIFICATE EXPIRY
+ * ------------------------------------
+ * An expired certificate fails every handshake at once, usually on a weekend. Three clocks tick:
+ *   - our server certificate (cert.pem): every client rejects us after NotAfter
+ *   - the client CA bundle (ca.pem): every client certificate it signed stops verifying
+ *   - each client certificate: only that client fails, and it is the one that notices last
+ * The monitor re-checks the first two periodically and logs EXPIRY WARNING well before the date.
+ * For clients it remembers the NotAfter of the last certificate each CN connected with, so
+ * GET /certs/expiring on the admin listener lists who has to renew soon.
+ */
+
+// expiryMonitor: Watches the server/CA certificates and records every client certificate seen
+type expiryMonitor struct {
+	warnBefore time.Duration // Certificates expiring within this window are reported
+
+	// serverCert / clientCAs: Current material; the reloader swaps it on rotation
+	serverCert func() *x509.Certificate
+	clientCAs  func() []*x509.Certificate
+
+	mu      sync.Mutex
+	clients map[string]clientCertRecord // client CN -> last certificate seen
+}
+
+// clientCertRecord: What we remember about a client's certificate
+type clientCertRecord struct {
+	CN           string
+	SerialNumber string
+	NotAfter     time.Time
+	LastSeen     time.Time
+}
+
+func newExpiryMonitor(warnBefore time.Duration, serverCert func() *x509.Certificate, clientCAs func() []*x509.Certificate) *expiryMonitor {
+	return &expiryMonitor{
+		warnBefore: warnBefore,
+		serverCert: serverCert,
+		clientCAs:  clientCAs,
+		clients:    make(map[string]clientCertRecord),
+	}
+}
+
+// observe: Records a verified client certificate. The first time a certificate inside the
+// warning window shows up it is logged, so the client's owner can be found in the logs.
+func (em *expiryMonitor) observe(cert *x509.Certificate) {
+	em.mu.Lock()
+	defer em.mu.Unlock()
+
+	cn := cert.Subject.CommonName
+	serial := cert.SerialNumber.String()
+	prev, known := em.clients[cn]
+	em.clients[cn] = clientCertRecord{CN: cn, SerialNumber: serial, NotAfter: cert.NotAfter, LastSeen: time.Now()}
+
+	if (!known || prev.SerialNumber != serial) && time.Until(cert.NotAfter) < em.warnBefore {
+		log.Printf("EXPIRY WARNING: client %q connected with certificate %s expiring %s (in %s)",
+			cn, serial, cert.NotAfter.Format(time.RFC3339), time.Until(cert.NotAfter).Round(time.Minute))
+	}
+}
+
+// middleware: Records the verified client certificate of every request (TLS or forwarded by a proxy)
+func (em *expiryMonitor) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if cert := verifiedPeer(r); cert != nil {
+			em.observe(cert)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// clientExpiries: CN -> NotAfter of the last certificate seen (for the metrics page)
+func (em *expiryMonitor) clientExpiries() map[string]time.Time {
+	em.mu.Lock()
+	defer em.mu.Unlock()
+
+	expiries := make(map[string]time.Time, len(em.clients))
+	for cn, rec := range em.clients {
+		expiries[cn] = rec.NotAfter
+	}
+	return expiries
+}
+
+// check: Logs a warning for the server certificate and every CA inside the window
+func (em *expiryMonitor) check() {
+	if cert := em.serverCert(); cert != nil {
+		logExpiry("server certificate", cert, em.warnBefore)
+	}
+	for _, ca := range em.clientCAs() {
+		logExpiry("client CA", ca, em.warnBefore)
+	}
+}
+
+// logExpiry: One log line per certificate that expires within warnBefore (or already has)
+func logExpiry(kind string, cert *x509.Certificate, warnBefore time.Duration) {
+	left := time.Until(cert.NotAfter)
+	switch {
+	case left <= 0:
+		log.Printf("EXPIRY ERROR: %s %q expired %s", kind, cert.Subject.CommonName, cert.NotAfter.Format(time.RFC3339))
+	case left < warnBefore:
+		log.Printf("EXPIRY WARNING: %s %q expires %s (in %s)",
+			kind, cert.Subject.CommonName, cert.NotAfter.Format(time.RFC3339), left.Round(time.Minute))
+	}
+}
+
+// watch: Checks once right away and then every interval, until ctx is cancelled
+func (em *expiryMonitor) watch(ctx context.Context, interval time.Duration) {
+	em.check()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			em.check()
+		}
+	}
+}
+
+// expiryStatus: One certificate in the /certs/expiring response
+type expiryStatus struct {
+	Subject          string     `json:"subject"`
+	SerialNumber     string     `json:"serial_number"`
+	NotAfter         time.Time  `json:"not_after"`
+	ExpiresInSeconds int64      `json:"expires_in_seconds"` // Negative once expired
+	Status           string     `json:"status"`             // "ok", "expiring" or "expired"
+	LastSeen         *time.Time `json:"last_seen,omitempty"`
+}
+
+func newExpiryStatus(subject, serial string, notAfter time.Time, warnBefore time.Duration) expiryStatus {
+	left := time.Until(notAfter)
+	status := "ok"
+	switch {
+	case left <= 0:
+		status = "expired"
+	case left < warnBefore:
+		status = "expiring"
+	}
+	return expiryStatus{
+		Subject:          subject,
+		SerialNumber:     serial,
+		NotAfter:         notAfter,
+		ExpiresInSeconds: int64(left.Seconds()),
+		Status:           status,
+	}
+}
+
+// expiryReport: Body of GET /certs/expiring
+type expiryReport struct {
+	WarnBefore string         `json:"warn_before"`
+	Server     *expiryStatus  `json:"server"`
+	ClientCAs  []expiryStatus `json:"client_cas"`
+	Clients    []expiryStatus `json:"clients"` // Only clients expiring within the window, soonest first
+}
+
+// ServeHTTP: GET /certs/expiring[?within=72h] on the admin listener
+func (em *expiryMonitor) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	window := em.warnBefore
+	if within := r.URL.Query().Get("within"); within != "" {
+		d, err := time.ParseDuration(within)
+		if err != nil || d < 0 {
+			writeJSONError(w, http.StatusBadRequest, "validation_failed", "invalid within parameter",
+				map[string]string{"within": "must be a duration like 72h"})
+			return
+		}
+		window = d
+	}
+
+	report := expiryReport{WarnBefore: window.String(), ClientCAs: []expiryStatus{}, Clients: []expiryStatus{}}
+	if cert := em.serverCert(); cert != nil {
+		status := newExpiryStatus(cert.Subject.String(), cert.SerialNumber.String(), cert.NotAfter, window)
+		report.Server = &status
+	}
+	for _, ca := range em.clientCAs() {
+		report.ClientCAs = append(report.ClientCAs, newExpiryStatus(ca.Subject.String(), ca.SerialNumber.String(), ca.NotAfter, window))
+	}
+
+	em.mu.Lock()
+	for _, rec := range em.clients {
+		if time.Until(rec.NotAfter) >= window {
+			continue
+		}
+		status := newExpiryStatus("CN="+rec.CN, rec.SerialNumber, rec.NotAfter, window)
+		status.LastSeen = &rec.LastSeen
+		report.Clients = append(report.Clients, status)
+	}
+	em.mu.Unlock()
+	slices.SortFunc(report.Clients, func(a, b expiryStatus) int {
+		return cmp.Or(a.NotAfter.Compare(b.NotAfter), cmp.Compare(a.Subject, b.Subject))
+	})
+
+	writeJSON(w, http.StatusOK, report)
+}
@@ -2,6 +2,15 @@ module server
 
 go 1.24.11
 
-require golang.org/x/net v0.48.0
+require (
+	github.com/quic-go/quic-go v0.59.1
+	golang.org/x/crypto v0.46.0
+	golang.org/x/net v0.48.0
+	software.sslmate.com/src/go-pkcs12 v0.7.3
+)
 
-require golang.org/x/text v0.32.0 // indirect
+require (
+	github.com/quic-go/qpack v0.6.0 // indirect
+	golang.org/x/sys v0.39.0 // indirect
+	golang.org/x/text v0.32.0 // indirect
+)
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+/**
+ * FUTURE REFERENCE: LIVENESS vs READINESS
+ * ---------------------------------------
+ *   /healthz  "Is the process alive?"         -> restart me if this fails. Never checks dependencies.
+ *   /readyz   "Can I serve traffic right now?" -> send me requests only if this passes.
+ *             Runs every registered dependency check (storage, RabbitMQ, ...) in parallel.
+ *             The public listener only says ok/fail per check; error text (hostnames, paths, driver
+ *             messages) goes to the log and to /readyz on the admin listener.
+ *   /debug/tls echoes what the handshake negotiated and the verified client chain, which is
+ *             usually the fastest way to find out why an mTLS client is rejected.
+ */
+
+// healthCheck: Returns nil when the dependency is usable
+type healthCheck func(ctx context.Context) error
+
+// namedCheck: A check plus the name it is reported under
+type namedCheck struct {
+	name  string
+	check healthCheck
+}
+
+// healthChecker: The set of readiness checks
+type healthChecker struct {
+	timeout  time.Duration
+	checks   []namedCheck
+	draining atomic.Bool // Set during shutdown; see timeouts.drain_delay for giving load balancers time to notice
+}
+
+func newHealthChecker(timeout time.Duration) *healthChecker {
+	return &healthChecker{timeout: timeout}
+}
+
+// add: Registers a readiness check; call before serving
+func (hc *healthChecker) add(name string, check healthCheck) {
+	hc.checks = append(hc.checks, namedCheck{name: name, check: check})
+}
+
+// setDraining: From now on /readyz reports 503. The caller keeps serving for the drain delay
+// before closing listeners, or load balancers polling /readyz would never see it.
+func (hc *healthChecker) setDraining() {
+	hc.draining.Store(true)
+}
+
+// checkResult: One entry of the /readyz body
+type checkResult struct {
+	Status    string  `json:"status"`          // "ok" or "fail"
+	Error     string  `json:"error,omitempty"` // Admin listener only
+	LatencyMS float64 `json:"latency_ms"`
+}
+
+// liveness: GET /healthz
+func (hc *healthChecker) liveness(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// readiness: GET /readyz on the public listener. Failures are logged, the body only says "fail".
+func (hc *healthChecker) readiness(w http.ResponseWriter, r *http.Request) {
+	hc.respond(w, r, false)
+}
+
+// readinessDetails: GET /readyz on the admin listener, including the error of every failed check
+func (hc *healthChecker) readinessDetails(w http.ResponseWriter, r *http.Request) {
+	hc.respond(w, r, true)
+}
+
+func (hc *healthChecker) respond(w http.ResponseWriter, r *http.Request, details bool) {
+	if hc.draining.Load() {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"status": "draining"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), hc.timeout)
+	defer cancel()
+
+	results := make(map[string]checkResult, len(hc.checks))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, c := range hc.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			start := time.Now()
+			err := c.check(ctx)
+
+			res := checkResult{Status: "ok", LatencyMS: float64(time.Since(start).Microseconds()) / 1000}
+			if err != nil {
+				res.Status = "fail"
+				if details {
+					res.Error = err.Error()
+				} else {
+					log.Printf("READINESS: check %s failed: %v", c.name, err)
+				}
+			}
+			mu.Lock()
+			results[c.name] = res
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	status, code := "ok", http.StatusOK
+	for _, res := range results {
+		if res.Status != "ok" {
+			status, code = "unavailable", http.StatusServiceUnavailable
+		}
+	}
+	writeJSON(w, code, map[string]any{"status": status, "checks": results})
+}
+
+// tcpCheck: The dependency accepts TCP connections (enough to tell RabbitMQ is up without speaking AMQP)
+func tcpCheck(addr string) healthCheck {
+	return func(ctx context.Context) error {
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, "tcp", addr)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+}
+
+// orderStoreCheck: The store answers a minimal query
+func orderStoreCheck(store orderStore) healthCheck {
+	return func(ctx context.Context) error {
+		_, _, err := store.List(ctx, orderFilter{Limit: 1})
+		return err
+	}
+}
+
+// certInfo: The interesting parts of one certificate in /debug/tls
+type certInfo struct {
+	Subject      string    `json:"subject"`
+	Issuer       string    `json:"issuer"`
+	SerialNumber string    `json:"serial_number"`
+	NotBefore    time.Time `json:"not_before"`
+	NotAfter     time.Time `json:"not_after"`
+	DNSNames     []string  `json:"dns_names,omitempty"`
+	IPAddresses  []string  `json:"ip_addresses,omitempty"`
+	URIs         []string  `json:"uris,omitempty"`
+	IsCA         bool      `json:"is_ca"`
+	SHA256       string    `json:"sha256_fingerprint"`
+}
+
+func newCertInfo(cert *x509.Certificate) certInfo {
+	sum := sha256.Sum256(cert.Raw)
+	info := certInfo{
+		Subject:      cert.Subject.String(),
+		Issuer:       cert.Issuer.String(),
+		SerialNumber: cert.SerialNumber.String(),
+		NotBefore:    cert.NotBefore,
+		NotAfter:     cert.NotAfter,
+		DNSNames:     cert.DNSNames,
+		IsCA:         cert.IsCA,
+		SHA256:       hex.EncodeToString(sum[:]),
+	}
+	for _, ip := range cert.IPAddresses {
+		info.IPAddresses = append(info.IPAddresses, ip.String())
+	}
+	for _, u := range cert.URIs {
+		info.URIs = append(info.URIs, u.String())
+	}
+	return info
+}
+
+// tlsDebugInfo: Body of /debug/tls
+type tlsDebugInfo struct {
+	Version            string       `json:"version"`
+	CipherSuite        string       `json:"cipher_suite"`
+	ALPN               string       `json:"alpn"`
+	ServerName         string       `json:"server_name"`
+	Resumed            bool         `json:"resumed"`
+	PeerCertificates   []certInfo   `json:"peer_certificates"` // As sent by the client
+	VerifiedChains     [][]certInfo `json:"verified_chains"`   // As built by our verification (leaf ... root)
+	ClientCertVerified bool         `json:"client_cert_verified"`
+}
+
+// debugTLS: GET /debug/tls
+func debugTLS(w http.ResponseWriter, r *http.Request) {
+	if r.TLS == nil {
+		writeJSONError(w, http.StatusBadRequest, "not_tls", "this connection is not using TLS", nil)
+		return
+	}
+	writeJSON(w, http.StatusOK, newTLSDebugInfo(r.TLS))
+}
+
+func newTLSDebugInfo(cs *tls.ConnectionState) tlsDebugInfo {
+	info := tlsDebugInfo{
+		Version:            getTLSVersionName(cs.Version),
+		CipherSuite:        tls.CipherSuiteName(cs.CipherSuite),
+		ALPN:               cs.NegotiatedProtocol,
+		ServerName:         cs.ServerName,
+		Resumed:            cs.DidResume,
+		PeerCertificates:   []certInfo{},
+		VerifiedChains:     [][]certInfo{},
+		ClientCertVerified: len(cs.VerifiedChains) > 0,
+	}
+	for _, cert := range cs.PeerCertificates {
+		info.PeerCertificates = append(info.PeerCertificates, newCertInfo(cert))
+	}
+	for _, chain := range cs.VerifiedChains {
+		infos := make([]certInfo, len(chain))
+		for i, cert := range chain {
+			infos[i] = newCertInfo(cert)
+		}
+		info.VerifiedChains = append(info.VerifiedChains, infos)
+	}
+	return info
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"log"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/quic-go/quic-go"       // QUIC transport (UDP)
+	"github.com/quic-go/quic-go/http3" // HTTP/3 on top of QUIC
+)
+
+/**
+ * FUTURE REFERENCE: HTTP/3
+ * ------------------------
+ * HTTP/3 runs over QUIC (UDP) instead of TCP, so one lost packet only stalls the stream it
+ * belongs to instead of the whole connection - a big win on lossy mobile networks.
+ * Clients never start with HTTP/3: they connect over TCP first, see the Alt-Svc response header
+ * ("h3 is also available on UDP port X") and switch for later requests.
+ * QUIC always uses TLS 1.3, and we hand it the SAME tls.Config (certificate reloader, client CA
+ * pool, revocation hook), so client certificates are verified exactly like on the TCP listener.
+ */
+
+// newHTTP3Server: Shares tlsConfig and handler with the TCP server
+func newHTTP3Server(addr string, tlsConfig *tls.Config, handler http.Handler) (*http3.Server, error) {
+	if tlsConfig.MaxVersion != 0 && tlsConfig.MaxVersion < tls.VersionTLS13 {
+		return nil, errors.New("HTTP/3 requires TLS 1.3, but the TLS profile caps the version below it")
+	}
+
+	return &http3.Server{
+		Addr:      addr,
+		TLSConfig: tlsConfig, // http3 clones it and sets ALPN to "h3" (also for GetConfigForClient)
+		Handler:   handler,
+		// 0-RTT data can be replayed by an attacker; refuse it so POST /orders is never executed twice
+		QUICConfig: &quic.Config{Allow0RTT: false},
+		Logger:     slog.Default(),
+	}, nil
+}
+
+// serveHTTP3: Runs the QUIC listener in the background. A listener that cannot start is fatal.
+func serveHTTP3(h3 *http3.Server) {
+	go func() {
+		if err := h3.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalln("STARTUP ERROR: HTTP/3 listener failed:", err)
+		}
+	}()
+}
+
+// advertiseHTTP3: Adds the Alt-Svc header to responses sent over TCP so clients discover the QUIC listener
+func advertiseHTTP3(h3 *http3.Server, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor < 3 {
+			// Fails only while the UDP socket is not bound yet; then there is nothing to advertise
+			_ = h3.SetQUICHeaders(w.Header())
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// shutdownHTTP3: Sends GOAWAY on every QUIC connection and waits up to drainTimeout for requests to finish
+func shutdownHTTP3(h3 *http3.Server, drainTimeout time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), drainTimeout)
+	defer cancel()
+
+	if err := h3.Shutdown(ctx); err != nil {
+		log.Printf("SHUTDOWN: HTTP/3 listener closed before all requests finished: %v", err)
+	}
+}
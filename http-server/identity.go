@@ -0,0 +1,51 @@
+package main
+
+import (
+	"crypto/x509"
+	"net/http"
+	"strings"
+)
+
+// clientIdentity: The parts of a client certificate we make decisions on
+type clientIdentity struct {
+	CN       string   `json:"cn"`
+	OUs      []string `json:"ou,omitempty"`
+	DNSNames []string `json:"dns,omitempty"`
+	URIs     []string `json:"uri,omitempty"`
+}
+
+// verifiedPeer: Returns the client's leaf certificate, but only if crypto/tls verified it
+// against our client CA pool. r.TLS.PeerCertificates alone is NOT proof of identity.
+// Behind a proxy (h2c mode) the leaf comes from the forwarded header, verified the same way (see proxy.go).
+func verifiedPeer(r *http.Request) *x509.Certificate {
+	if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 && len(r.TLS.VerifiedChains[0]) > 0 {
+		return r.TLS.VerifiedChains[0][0]
+	}
+	if chain, ok := r.Context().Value(forwardedChainKey).([]*x509.Certificate); ok && len(chain) > 0 {
+		return chain[0]
+	}
+	return nil
+}
+
+// identityFromCert: Flattens the subject and SAN fields of a certificate
+func identityFromCert(cert *x509.Certificate) clientIdentity {
+	id := clientIdentity{
+		CN:       cert.Subject.CommonName,
+		OUs:      cert.Subject.OrganizationalUnit,
+		DNSNames: cert.DNSNames,
+	}
+	for _, u := range cert.URIs {
+		id.URIs = append(id.URIs, u.String())
+	}
+	return id
+}
+
+// spiffeID: The first URI SAN using the spiffe:// scheme (SPIFFE allows exactly one)
+func (id clientIdentity) spiffeID() string {
+	for _, u := range id.URIs {
+		if strings.HasPrefix(u, "spiffe://") {
+			return u
+		}
+	}
+	return ""
+}
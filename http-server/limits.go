@@ -0,0 +1,236 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"net/http"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+/**
+ * FUTURE REFERENCE: DEFENSIVE LIMITS
+ * ----------------------------------
+ * A verified client certificate says who is calling, not that the caller behaves. The server
+ * protects itself on three levels:
+ *   - Connection: ReadHeaderTimeout / ReadTimeout / IdleTimeout / MaxHeaderBytes on http.Server
+ *     (config "timeouts" and "max_header_bytes") stop slowloris clients that trickle bytes in
+ *     to hold connections open forever.
+ *   - Request: bodies are cut off at limits.max_body_bytes, and every handler gets a deadline
+ *     (limits.handler_timeout, or a per-route value keyed by the pattern shown in /metrics,
+ *     e.g. "GET /orders/{id}"). A handler that overruns answers 503 instead of hanging.
+ *     The deadline works by buffering the whole response (so it can still be swapped for the 503),
+ *     which means handlers cannot stream: Flush is not available and nothing reaches the client
+ *     before the handler returns. Keep streaming endpoints out of this stack.
+ *   - Bugs: a panicking handler answers a JSON 500 carrying the request ID, so the caller can
+ *     quote it and we can find the stack trace in the log.
+ */
+
+// limitBody: Rejects bodies announced larger than maxBytes right away and cuts off the rest while reading.
+// Handlers see an *http.MaxBytesError from Read (decodeJSONBody turns it into a 413).
+func limitBody(maxBytes int64, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ContentLength > maxBytes {
+			writeJSONError(w, http.StatusRequestEntityTooLarge, "body_too_large",
+				fmt.Sprintf("request body must not exceed %d bytes", maxBytes), nil)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// routeTimeouts: The handler deadline for each route, falling back to a default
+type routeTimeouts struct {
+	def      time.Duration
+	perRoute map[string]time.Duration     // router pattern -> timeout
+	routeOf  func(r *http.Request) string // Same lookup the metrics use
+}
+
+// middleware: Runs the handler under its route's deadline (see handlerTimeout)
+func (rt routeTimeouts) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		d, ok := rt.perRoute[rt.routeOf(r)]
+		if !ok {
+			d = rt.def
+		}
+		handlerTimeout(d, next).ServeHTTP(w, r)
+	})
+}
+
+// handlerPanic: A panic caught on the handler goroutine, carried over to the request goroutine
+type handlerPanic struct {
+	value any
+	stack []byte
+}
+
+// handlerTimeout: Like http.TimeoutHandler, but answers with an apiError and re-raises panics on the
+// request goroutine so recoverPanics sees them. The handler goroutine is NOT stopped at the deadline:
+// its ctx is cancelled and its late writes are discarded, so work that ignores ctx still completes.
+// Store writes go through startWrite: one that starts after the deadline is refused, and once one has
+// started the handler is waited for instead, so a 503 never hides a change that was stored.
+func handlerTimeout(d time.Duration, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), d)
+		defer cancel()
+		guard := &writeGuard{}
+		ctx = context.WithValue(ctx, writeGuardKey{}, guard)
+
+		tw := &timeoutWriter{header: make(http.Header)}
+		done := make(chan struct{})
+		panicked := make(chan handlerPanic, 1)
+		go func() {
+			defer func() {
+				if p := recover(); p != nil {
+					panicked <- handlerPanic{value: p, stack: debug.Stack()}
+					return
+				}
+				close(done)
+			}()
+			next.ServeHTTP(tw, r.WithContext(ctx))
+		}()
+
+		select {
+		case p := <-panicked:
+			panic(p)
+		case <-done:
+			tw.writeTo(w)
+			return
+		case <-ctx.Done():
+		}
+
+		if guard.started() {
+			// A store write began in time: the answer has to say what happened to it
+			select {
+			case p := <-panicked:
+				panic(p)
+			case <-done:
+				tw.writeTo(w)
+			case <-r.Context().Done(): // The client went away
+			}
+			return
+		}
+
+		tw.mu.Lock()
+		tw.timedOut = true
+		tw.mu.Unlock()
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			writeJSONError(w, http.StatusServiceUnavailable, "handler_timeout",
+				fmt.Sprintf("the request did not complete within %s", d), nil)
+		}
+		// Otherwise the client went away; there is nobody left to answer
+	})
+}
+
+// writeGuard: Settles the race between the handler deadline and the first store write of a request
+type writeGuard struct {
+	mu      sync.Mutex
+	writing bool
+}
+
+type writeGuardKey struct{}
+
+// started: Whether a store write got in before the deadline
+func (g *writeGuard) started() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.writing
+}
+
+// startWrite: Stores call it before changing anything. The first write of a request is refused once
+// ctx is done; after that, later writes of the same request go through so the request stays whole.
+func startWrite(ctx context.Context) error {
+	g, ok := ctx.Value(writeGuardKey{}).(*writeGuard)
+	if !ok {
+		return ctx.Err()
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if !g.writing {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		g.writing = true
+	}
+	return nil
+}
+
+// timeoutWriter: Buffers the response so a timeout can still replace it with a clean 503
+type timeoutWriter struct {
+	mu       sync.Mutex
+	header   http.Header
+	buf      bytes.Buffer
+	status   int
+	timedOut bool
+}
+
+// writeTo: Sends the buffered response once the handler has returned
+func (tw *timeoutWriter) writeTo(w http.ResponseWriter) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	maps.Copy(w.Header(), tw.header)
+	w.WriteHeader(cmp.Or(tw.status, http.StatusOK))
+	w.Write(tw.buf.Bytes())
+}
+
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.header
+}
+
+func (tw *timeoutWriter) WriteHeader(status int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut || tw.status != 0 {
+		return
+	}
+	tw.status = status
+}
+
+func (tw *timeoutWriter) Write(p []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	if tw.status == 0 {
+		tw.status = http.StatusOK
+	}
+	return tw.buf.Write(p)
+}
+
+// recoverPanics: Turns a handler panic into a JSON 500 with the request ID instead of a dropped connection.
+// Must run inside accessLog (for the request ID) and inside the metrics (so the 500 is counted).
+func recoverPanics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := &statusRecorder{ResponseWriter: w}
+		defer func() {
+			p := recover()
+			if p == nil {
+				return
+			}
+
+			stack := debug.Stack()
+			if hp, ok := p.(handlerPanic); ok {
+				p, stack = hp.value, hp.stack // Re-raised by handlerTimeout from the handler goroutine
+			}
+			if p == http.ErrAbortHandler {
+				panic(p) // The documented way to abort a response on purpose; net/http handles it quietly
+			}
+			id := requestIDFrom(r.Context())
+			log.Printf("PANIC: %s %s (request_id=%s): %v\n%s", r.Method, r.URL.Path, id, p, stack)
+
+			if rec.status != 0 {
+				return // Headers are already out; the client gets a truncated response
+			}
+			writeJSONError(w, http.StatusInternalServerError, "internal_error",
+				"the server hit an unexpected error", map[string]string{"request_id": id})
+		}()
+		next.ServeHTTP(rec, r)
+	})
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// decodeAPIError: The JSON error body written by writeJSONError
+func decodeAPIError(t *testing.T, rec *httptest.ResponseRecorder) apiError {
+	t.Helper()
+	var body apiError
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("response is not a JSON error: %v (%q)", err, rec.Body.String())
+	}
+	return body
+}
+
+// quietLog: Silences PANIC logs for the duration of a test
+func quietLog(t *testing.T) {
+	t.Helper()
+	previous := log.Writer()
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() { log.SetOutput(previous) })
+}
+
+func TestLimitBody(t *testing.T) {
+	var readErr error
+	h := limitBody(10, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, readErr = io.ReadAll(r.Body)
+	}))
+
+	// Announced too large: rejected before the handler runs
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(strings.Repeat("x", 11))))
+	if rec.Code != http.StatusRequestEntityTooLarge || decodeAPIError(t, rec).Error != "body_too_large" {
+		t.Errorf("announced body: got %d %q, want 413 body_too_large", rec.Code, rec.Body.String())
+	}
+
+	// Unknown length (chunked): cut off while reading
+	req := httptest.NewRequest(http.MethodPost, "/", io.NopCloser(strings.NewReader(strings.Repeat("x", 11))))
+	req.ContentLength = -1
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	var tooLarge *http.MaxBytesError
+	if !errors.As(readErr, &tooLarge) {
+		t.Errorf("chunked body: read error = %v, want *http.MaxBytesError", readErr)
+	}
+
+	// Within the limit
+	readErr = nil
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", strings.NewReader("small")))
+	if rec.Code != http.StatusOK || readErr != nil {
+		t.Errorf("small body: got %d, read error %v", rec.Code, readErr)
+	}
+}
+
+func TestHandlerTimeout(t *testing.T) {
+	slow := handlerTimeout(20*time.Millisecond, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+		w.Write([]byte("too late"))
+	}))
+	rec := httptest.NewRecorder()
+	slow.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusServiceUnavailable || decodeAPIError(t, rec).Error != "handler_timeout" {
+		t.Errorf("slow handler: got %d %q, want 503 handler_timeout", rec.Code, rec.Body.String())
+	}
+
+	fast := handlerTimeout(time.Second, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Test", "kept")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("hello "))
+		w.Write([]byte("world"))
+	}))
+	rec = httptest.NewRecorder()
+	fast.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusCreated || rec.Body.String() != "hello world" || rec.Header().Get("X-Test") != "kept" {
+		t.Errorf("fast handler: got %d %q (X-Test %q)", rec.Code, rec.Body.String(), rec.Header().Get("X-Test"))
+	}
+}
+
+func TestHandlerTimeoutStopsStoreWrites(t *testing.T) {
+	store := newMemoryOrderStore()
+	created := make(chan error, 1)
+	h := handlerTimeout(10*time.Millisecond, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done() // Slow work that overruns the deadline
+		created <- store.Create(r.Context(), order{ID: "late"})
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/orders", nil))
+
+	if err := <-created; !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Create after the deadline: err = %v, want context.DeadlineExceeded", err)
+	}
+	if _, err := store.Get(context.Background(), "late"); !errors.Is(err, errOrderNotFound) {
+		t.Errorf("order created after the handler timed out")
+	}
+}
+
+func TestHandlerTimeoutFileStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "orders.json")
+	store, err := newFileOrderStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Written in time but answered late: the client must learn the order exists, not get a 503
+	committed := handlerTimeout(10*time.Millisecond, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := store.Create(r.Context(), order{ID: "early"}); err != nil {
+			t.Errorf("Create before the deadline: %v", err)
+		}
+		<-r.Context().Done()
+		w.WriteHeader(http.StatusCreated)
+	}))
+	rec := httptest.NewRecorder()
+	committed.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/orders", nil))
+	if rec.Code != http.StatusCreated {
+		t.Errorf("write before the deadline: got %d %q, want 201", rec.Code, rec.Body.String())
+	}
+
+	// Reaches the store after the deadline: 503, and nothing on disk
+	created := make(chan error, 1)
+	late := handlerTimeout(10*time.Millisecond, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+		created <- store.Create(r.Context(), order{ID: "late"})
+	}))
+	rec = httptest.NewRecorder()
+	late.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/orders", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("write after the deadline: got %d, want 503", rec.Code)
+	}
+	if err := <-created; !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Create after the deadline: err = %v, want context.DeadlineExceeded", err)
+	}
+
+	reopened, err := newFileOrderStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reopened.Get(context.Background(), "early"); err != nil {
+		t.Errorf("order written before the deadline is not on disk: %v", err)
+	}
+	if _, err := reopened.Get(context.Background(), "late"); !errors.Is(err, errOrderNotFound) {
+		t.Errorf("order written after the deadline reached the disk")
+	}
+}
+
+func TestRecoverPanics(t *testing.T) {
+	quietLog(t)
+	boom := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { panic("boom") })
+
+	for name, h := range map[string]http.Handler{
+		"direct":          recoverPanics(boom),
+		"through timeout": recoverPanics(handlerTimeout(time.Second, boom)),
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req = req.WithContext(context.WithValue(req.Context(), requestIDKey, "req-42"))
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+
+		body := decodeAPIError(t, rec)
+		details, _ := body.Details.(map[string]any)
+		if rec.Code != http.StatusInternalServerError || body.Error != "internal_error" || details["request_id"] != "req-42" {
+			t.Errorf("%s: got %d %q, want 500 internal_error with request_id req-42", name, rec.Code, rec.Body.String())
+		}
+	}
+}
+
+func TestRecoverPanicsLetsAbortThrough(t *testing.T) {
+	quietLog(t)
+	abort := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { panic(http.ErrAbortHandler) })
+
+	for name, h := range map[string]http.Handler{
+		"direct":          recoverPanics(abort),
+		"through timeout": recoverPanics(handlerTimeout(time.Second, abort)),
+	} {
+		rec := httptest.NewRecorder()
+		func() {
+			defer func() {
+				if p := recover(); p != http.ErrAbortHandler {
+					t.Errorf("%s: recovered %v, want http.ErrAbortHandler", name, p)
+				}
+			}()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		}()
+		if rec.Body.Len() != 0 {
+			t.Errorf("%s: aborted request got a body: %q", name, rec.Body.String())
+		}
+	}
+}
+
+func TestRouteTimeouts(t *testing.T) {
+	routes := newRouter()
+	work := func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(50 * time.Millisecond):
+			w.Write([]byte("ok"))
+		case <-r.Context().Done():
+		}
+	}
+	routes.HandleFunc(http.MethodGet, "/slow", work)
+	routes.HandleFunc(http.MethodGet, "/fast", work)
+
+	h := routeTimeouts{
+		def:      time.Second,
+		perRoute: map[string]time.Duration{"GET /slow": 10 * time.Millisecond},
+		routeOf:  routes.route,
+	}.middleware(routes)
+
+	for path, want := range map[string]int{"/slow": http.StatusServiceUnavailable, "/fast": http.StatusOK} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != want {
+			t.Errorf("GET %s: got %d, want %d", path, rec.Code, want)
+		}
+	}
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+/**
+ * FUTURE REFERENCE: PROMETHEUS TEXT FORMAT
+ * ----------------------------------------
+ * Prometheus scrapes a plain text page that looks like:
+ *   # TYPE http_requests_total counter
+ *   http_requests_total{route="/orders",method="GET",code="200",proto="HTTP/2.0"} 42
+ * The format is simple enough that a few maps and a mutex cover everything this server
+ * needs, without pulling in the full client library.
+ */
+
+// latencyBuckets: Upper bounds (seconds) of the request duration histogram, Prometheus' defaults
+var latencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// requestKey: Label set of http_requests_total
+type requestKey struct {
+	route, method, code, proto string
+}
+
+// histogram: Cumulative bucket counts plus sum and count, as Prometheus expects
+type histogram struct {
+	buckets []uint64 // buckets[i] counts observations <= latencyBuckets[i]
+	sum     float64
+	count   uint64
+}
+
+func (h *histogram) observe(v float64) {
+	for i, bound := range latencyBuckets {
+		if v <= bound {
+			h.buckets[i]++
+		}
+	}
+	h.sum += v
+	h.count++
+}
+
+// serverMetrics: Every counter the /metrics endpoint exposes
+type serverMetrics struct {
+	inFlight *inFlightTracker             // Shared with graceful shutdown
+	routeOf  func(r *http.Request) string // Maps a request to its registered pattern (bounded label values)
+	expiry   *expiryMonitor               // Server and client certificate NotAfter dates (see expiry.go)
+
+	mu                sync.Mutex
+	requests          map[requestKey]uint64
+	latency           map[string]*histogram // route -> histogram
+	handshakeFailures map[string]uint64     // reason -> count
+}
+
+// newServerMetrics: routeOf must return a small, fixed set of values (e.g. mux patterns), never raw paths
+func newServerMetrics(inFlight *inFlightTracker, routeOf func(r *http.Request) string, expiry *expiryMonitor) *serverMetrics {
+	return &serverMetrics{
+		inFlight:          inFlight,
+		routeOf:           routeOf,
+		expiry:            expiry,
+		requests:          make(map[requestKey]uint64),
+		latency:           make(map[string]*histogram),
+		handshakeFailures: make(map[string]uint64),
+	}
+}
+
+// middleware: Records count and latency for every request
+func (m *serverMetrics) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		route := m.routeOf(r)
+		if route == "" {
+			route = "unmatched"
+		}
+
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+		elapsed := time.Since(start).Seconds()
+
+		m.mu.Lock()
+		defer m.mu.Unlock()
+
+		m.requests[requestKey{route: route, method: methodLabel(r.Method), code: strconv.Itoa(rec.status), proto: r.Proto}]++
+
+		h, ok := m.latency[route]
+		if !ok {
+			h = &histogram{buckets: make([]uint64, len(latencyBuckets))}
+			m.latency[route] = h
+		}
+		h.observe(elapsed)
+	})
+}
+
+// methodLabel: Clients choose the method freely, so anything outside the standard set shares one label
+// instead of adding a series per made-up method
+func methodLabel(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return method
+	default:
+		return "other"
+	}
+}
+
+// observeHandshakeFailure: Counts one failed TLS handshake
+func (m *serverMetrics) observeHandshakeFailure(reason string) {
+	m.mu.Lock()
+	m.handshakeFailures[reason]++
+	m.mu.Unlock()
+}
+
+// handshakeFailureReason: net/http only reports handshake failures as log lines,
+// so we classify the error text into a handful of stable reasons
+func handshakeFailureReason(msg string) string {
+	switch {
+	case strings.Contains(msg, "didn't provide a certificate"):
+		return "no_client_cert"
+	case strings.Contains(msg, "revoked"):
+		return "revoked"
+	case strings.Contains(msg, "expired or is not yet valid"):
+		return "cert_expired"
+	case strings.Contains(msg, "unknown authority"):
+		return "unknown_ca"
+	case strings.Contains(msg, "certificate"):
+		return "bad_certificate"
+	case strings.Contains(msg, "protocol version"):
+		return "protocol_version"
+	case strings.Contains(msg, "no cipher suite"):
+		return "no_cipher_suite"
+	case strings.Contains(msg, "application protocol"):
+		return "no_alpn_protocol"
+	case strings.Contains(msg, "does not look like a TLS handshake"), strings.Contains(msg, "HTTP request to an HTTPS server"):
+		return "not_tls"
+	case strings.Contains(msg, "timeout"):
+		return "timeout"
+	case strings.Contains(msg, "EOF"), strings.Contains(msg, "connection reset"):
+		return "client_closed"
+	default:
+		return "other"
+	}
+}
+
+// serverErrorLog: Used as http.Server.ErrorLog. Counts handshake failures, then logs the line as usual.
+func (m *serverMetrics) serverErrorLog() *log.Logger {
+	return log.New(handshakeLogWriter{m}, "", 0)
+}
+
+type handshakeLogWriter struct{ m *serverMetrics }
+
+func (hw handshakeLogWriter) Write(p []byte) (int, error) {
+	msg := strings.TrimSpace(string(p))
+	if strings.Contains(msg, "TLS handshake error") {
+		hw.m.observeHandshakeFailure(handshakeFailureReason(msg))
+	}
+	log.Print(msg)
+	return len(p), nil
+}
+
+// ServeHTTP: The /metrics endpoint
+func (m *serverMetrics) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.writeTo(w)
+}
+
+// writeTo: Renders every metric in the Prometheus text exposition format (sorted for stable output)
+func (m *serverMetrics) writeTo(w io.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	fmt.Fprintln(w, "# HELP http_requests_total Requests served, by route, method, status code and protocol.")
+	fmt.Fprintln(w, "# TYPE http_requests_total counter")
+	keys := make([]requestKey, 0, len(m.requests))
+	for k := range m.requests {
+		keys = append(keys, k)
+	}
+	slices.SortFunc(keys, func(a, b requestKey) int {
+		return strings.Compare(a.route+a.method+a.code+a.proto, b.route+b.method+b.code+b.proto)
+	})
+	for _, k := range keys {
+		fmt.Fprintf(w, "http_requests_total{route=%s,method=%s,code=%s,proto=%s} %d\n",
+			quote(k.route), quote(k.method), quote(k.code), quote(k.proto), m.requests[k])
+	}
+
+	fmt.Fprintln(w, "# HELP http_request_duration_seconds Request latency, by route.")
+	fmt.Fprintln(w, "# TYPE http_request_duration_seconds histogram")
+	for _, route := range sortedKeys(m.latency) {
+		h := m.latency[route]
+		for i, bound := range latencyBuckets {
+			fmt.Fprintf(w, "http_request_duration_seconds_bucket{route=%s,le=%q} %d\n",
+				quote(route), strconv.FormatFloat(bound, 'g', -1, 64), h.buckets[i])
+		}
+		fmt.Fprintf(w, "http_request_duration_seconds_bucket{route=%s,le=\"+Inf\"} %d\n", quote(route), h.count)
+		fmt.Fprintf(w, "http_request_duration_seconds_sum{route=%s} %g\n", quote(route), h.sum)
+		fmt.Fprintf(w, "http_request_duration_seconds_count{route=%s} %d\n", quote(route), h.count)
+	}
+
+	fmt.Fprintln(w, "# HELP http_requests_in_flight Requests currently being served.")
+	fmt.Fprintln(w, "# TYPE http_requests_in_flight gauge")
+	fmt.Fprintf(w, "http_requests_in_flight %d\n", m.inFlight.count())
+
+	fmt.Fprintln(w, "# HELP tls_handshake_failures_total Failed TLS handshakes, by reason.")
+	fmt.Fprintln(w, "# TYPE tls_handshake_failures_total counter")
+	for _, reason := range sortedKeys(m.handshakeFailures) {
+		fmt.Fprintf(w, "tls_handshake_failures_total{reason=%s} %d\n", quote(reason), m.handshakeFailures[reason])
+	}
+
+	if cert := m.expiry.serverCert(); cert != nil {
+		fmt.Fprintln(w, "# HELP tls_server_cert_expiry_seconds Seconds until the served certificate expires.")
+		fmt.Fprintln(w, "# TYPE tls_server_cert_expiry_seconds gauge")
+		fmt.Fprintf(w, "tls_server_cert_expiry_seconds %.0f\n", time.Until(cert.NotAfter).Seconds())
+	}
+
+	fmt.Fprintln(w, "# HELP tls_client_cert_expiry_seconds Seconds until the last seen certificate of each client expires.")
+	fmt.Fprintln(w, "# TYPE tls_client_cert_expiry_seconds gauge")
+	clientExpiry := m.expiry.clientExpiries()
+	for _, cn := range sortedKeys(clientExpiry) {
+		fmt.Fprintf(w, "tls_client_cert_expiry_seconds{cn=%s} %.0f\n", quote(cn), time.Until(clientExpiry[cn]).Seconds())
+	}
+}
+
+// quote: Label values escape backslash, double quote and newline
+func quote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s) + `"`
+}
+
+// sortedKeys: Map keys in a stable order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
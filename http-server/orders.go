@@ -0,0 +1,303 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/**
+ * FUTURE REFERENCE: ORDERS API
+ * ----------------------------
+ *   GET    /orders?status=paid&customer=Everest&limit=20&offset=0   -> page of orders
+ *   GET    /orders/{id}                                             -> one order
+ *   POST   /orders        {"amount": 30, "customer": {"name": "Everest"}}
+ *   PATCH  /orders/{id}   {"status": "paid"}
+ *   DELETE /orders/{id}
+ * The order / customer shapes come from struct/struct.go; here the fields are exported
+ * (with json tags) because encoding/json can only see exported fields.
+ */
+
+// customer: Same shape as struct/struct.go
+type customer struct {
+	Name string `json:"name"`
+}
+
+// order: Same shape as struct/struct.go
+type order struct {
+	ID        string    `json:"id"`
+	Amount    float32   `json:"amount"`
+	Status    string    `json:"status"`
+	CreatedAt time.Time `json:"createdAt"` // nanosecond precision
+	Customer  customer  `json:"customer"`
+}
+
+// Order lifecycle: new -> paid -> shipped -> delivered, and anything before delivery may be cancelled
+const (
+	statusNew       = "new"
+	statusPaid      = "paid"
+	statusShipped   = "shipped"
+	statusDelivered = "delivered"
+	statusCancelled = "cancelled"
+)
+
+// statusTransitions: Which status may follow which (terminal states have no entry)
+var statusTransitions = map[string][]string{
+	statusNew:     {statusPaid, statusCancelled},
+	statusPaid:    {statusShipped, statusCancelled},
+	statusShipped: {statusDelivered, statusCancelled},
+}
+
+// validStatus: Known status values
+func validStatus(status string) bool {
+	switch status {
+	case statusNew, statusPaid, statusShipped, statusDelivered, statusCancelled:
+		return true
+	}
+	return false
+}
+
+// Pagination defaults
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+	maxOrderBody    = 1 << 20 // 1 MiB is plenty for one order
+)
+
+// newOrder: constructor like in struct/struct.go, with a generated id
+func newOrder(amount float32, customerName string) order {
+	return order{
+		ID:        strings.ToLower(rand.Text()),
+		Amount:    amount,
+		Status:    statusNew,
+		CreatedAt: time.Now().UTC(),
+		Customer:  customer{Name: customerName},
+	}
+}
+
+// changeStatus: receiver method like in struct/struct.go, but refusing impossible transitions
+func (o *order) changeStatus(status string) error {
+	if !validStatus(status) {
+		return fmt.Errorf("unknown status %q", status)
+	}
+	if status == o.Status {
+		return nil
+	}
+	if !slices.Contains(statusTransitions[o.Status], status) {
+		return fmt.Errorf("cannot change status from %q to %q", o.Status, status)
+	}
+	o.Status = status
+	return nil
+}
+
+// ordersAPI: HTTP handlers on top of an orderStore
+type ordersAPI struct {
+	store orderStore
+}
+
+// register: Adds the orders routes to a group mounted at /orders.
+// Routes are method-specific, so other methods get 405 with an Allow header.
+func (api *ordersAPI) register(g *router) {
+	g.HandleFunc(http.MethodGet, "", api.list)
+	g.HandleFunc(http.MethodPost, "", api.create)
+	g.HandleFunc(http.MethodGet, "/{id}", api.get)
+	g.HandleFunc(http.MethodPatch, "/{id}", api.updateStatus)
+	g.HandleFunc(http.MethodDelete, "/{id}", api.delete)
+}
+
+// orderPage: Response body of GET /orders
+type orderPage struct {
+	Items  []order `json:"items"`
+	Total  int     `json:"total"`
+	Limit  int     `json:"limit"`
+	Offset int     `json:"offset"`
+}
+
+func (api *ordersAPI) list(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	filter := orderFilter{
+		Status:   query.Get("status"),
+		Customer: query.Get("customer"),
+		Limit:    defaultPageSize,
+	}
+	if filter.Status != "" && !validStatus(filter.Status) {
+		writeJSONError(w, http.StatusBadRequest, "invalid_query", "unknown status filter", map[string]string{"status": filter.Status})
+		return
+	}
+
+	var err error
+	if filter.Limit, err = intParam(query, "limit", defaultPageSize, 1, maxPageSize); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid_query", err.Error(), nil)
+		return
+	}
+	if filter.Offset, err = intParam(query, "offset", 0, 0, -1); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid_query", err.Error(), nil)
+		return
+	}
+
+	items, total, err := api.store.List(r.Context(), filter)
+	if err != nil {
+		api.storeError(w, r, err)
+		return
+	}
+	if items == nil {
+		items = []order{} // Encode an empty page as [] rather than null
+	}
+
+	// RFC 8288 Link header so clients can follow pages without building URLs themselves
+	if next := filter.Offset + len(items); next < total {
+		nextQuery := url.Values{}
+		for k, v := range query {
+			nextQuery[k] = v
+		}
+		nextQuery.Set("limit", strconv.Itoa(filter.Limit))
+		nextQuery.Set("offset", strconv.Itoa(next))
+		w.Header().Set("Link", fmt.Sprintf(`<%s?%s>; rel="next"`, r.URL.Path, nextQuery.Encode()))
+	}
+
+	writeJSON(w, http.StatusOK, orderPage{Items: items, Total: total, Limit: filter.Limit, Offset: filter.Offset})
+}
+
+func (api *ordersAPI) get(w http.ResponseWriter, r *http.Request) {
+	o, err := api.store.Get(r.Context(), r.PathValue("id"))
+	if err != nil {
+		api.storeError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, o)
+}
+
+// createOrderRequest: Fields a client may set; id, status and createdAt are assigned by the server
+type createOrderRequest struct {
+	Amount   float32  `json:"amount"`
+	Customer customer `json:"customer"`
+}
+
+func (api *ordersAPI) create(w http.ResponseWriter, r *http.Request) {
+	var req createOrderRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+
+	problems := map[string]string{}
+	if req.Amount <= 0 {
+		problems["amount"] = "must be greater than 0"
+	}
+	if strings.TrimSpace(req.Customer.Name) == "" {
+		problems["customer.name"] = "is required"
+	}
+	if len(problems) > 0 {
+		writeJSONError(w, http.StatusUnprocessableEntity, "validation_failed", "order is invalid", problems)
+		return
+	}
+
+	o := newOrder(req.Amount, strings.TrimSpace(req.Customer.Name))
+	if err := api.store.Create(r.Context(), o); err != nil {
+		api.storeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Location", "/orders/"+o.ID)
+	writeJSON(w, http.StatusCreated, o)
+}
+
+// updateStatusRequest: Body of PATCH /orders/{id}
+type updateStatusRequest struct {
+	Status string `json:"status"`
+}
+
+func (api *ordersAPI) updateStatus(w http.ResponseWriter, r *http.Request) {
+	var req updateStatusRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+	if req.Status == "" {
+		writeJSONError(w, http.StatusUnprocessableEntity, "validation_failed", "order is invalid", map[string]string{"status": "is required"})
+		return
+	}
+
+	var transitionErr error
+	o, err := api.store.Update(r.Context(), r.PathValue("id"), func(o *order) error {
+		transitionErr = o.changeStatus(req.Status)
+		return transitionErr
+	})
+	if transitionErr != nil {
+		writeJSONError(w, http.StatusConflict, "invalid_transition", transitionErr.Error(), nil)
+		return
+	}
+	if err != nil {
+		api.storeError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, o)
+}
+
+func (api *ordersAPI) delete(w http.ResponseWriter, r *http.Request) {
+	if err := api.store.Delete(r.Context(), r.PathValue("id")); err != nil {
+		api.storeError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// storeError: 404 for unknown ids, 500 (details only in the log) for everything else
+func (api *ordersAPI) storeError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, errOrderNotFound) {
+		writeJSONError(w, http.StatusNotFound, "not_found", "order not found", map[string]string{"id": r.PathValue("id")})
+		return
+	}
+	log.Printf("ORDERS ERROR: %s %s: %v", r.Method, r.URL.Path, err)
+	writeJSONError(w, http.StatusInternalServerError, "internal_error", "could not access order storage", nil)
+}
+
+// decodeJSONBody: Strict decoding (size limit, no unknown fields, exactly one object).
+// Writes the error response itself and returns false when the body is unusable.
+func decodeJSONBody(w http.ResponseWriter, r *http.Request, v any) bool {
+	if ct := r.Header.Get("Content-Type"); ct != "" && !strings.HasPrefix(ct, "application/json") {
+		writeJSONError(w, http.StatusUnsupportedMediaType, "unsupported_media_type", "body must be application/json", nil)
+		return false
+	}
+
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxOrderBody))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeJSONError(w, http.StatusRequestEntityTooLarge, "body_too_large", err.Error(), nil)
+			return false
+		}
+		writeJSONError(w, http.StatusBadRequest, "invalid_body", err.Error(), nil)
+		return false
+	}
+	if _, err := dec.Token(); !errors.Is(err, io.EOF) {
+		writeJSONError(w, http.StatusBadRequest, "invalid_body", "body must contain a single JSON object", nil)
+		return false
+	}
+	return true
+}
+
+// intParam: Parses an optional integer query parameter within [lo, hi] (hi < 0 means unbounded)
+func intParam(query url.Values, name string, def, lo, hi int) (int, error) {
+	raw := query.Get(name)
+	if raw == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil || n < lo || (hi >= 0 && n > hi) {
+		if hi >= 0 {
+			return 0, fmt.Errorf("%s must be an integer between %d and %d", name, lo, hi)
+		}
+		return 0, fmt.Errorf("%s must be an integer >= %d", name, lo)
+	}
+	return n, nil
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+)
+
+/**
+ * FUTURE REFERENCE: STORAGE INTERFACE
+ * -----------------------------------
+ * Handlers only talk to orderStore, so swapping the in-memory map for a file, SQL database
+ * or remote service is a matter of writing one more type with these five methods.
+ */
+
+// errOrderNotFound: Returned by every store when the id does not exist
+var errOrderNotFound = errors.New("order not found")
+
+// orderFilter: Query options for List. Empty strings mean "no filter".
+type orderFilter struct {
+	Status   string
+	Customer string // Case-insensitive match on customer.name
+	Limit    int
+	Offset   int
+}
+
+// orderStore: What the orders API needs from a storage backend.
+// Create, Update and Delete must call startWrite before changing anything and give up if it fails:
+// handlerTimeout has answered 503 by then, and the client will assume nothing happened.
+type orderStore interface {
+	// List returns one page of matching orders (oldest first) and the total number of matches
+	List(ctx context.Context, filter orderFilter) ([]order, int, error)
+	Get(ctx context.Context, id string) (order, error)
+	Create(ctx context.Context, o order) error
+	// Update applies fn to the stored order atomically; if fn returns an error nothing changes
+	Update(ctx context.Context, id string, fn func(*order) error) (order, error)
+	Delete(ctx context.Context, id string) error
+}
+
+// memoryOrderStore: A map guarded by a RWMutex. Contents are lost on restart.
+type memoryOrderStore struct {
+	mu     sync.RWMutex
+	orders map[string]order
+}
+
+func newMemoryOrderStore() *memoryOrderStore {
+	return &memoryOrderStore{orders: make(map[string]order)}
+}
+
+func (s *memoryOrderStore) List(_ context.Context, filter orderFilter) ([]order, int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var matches []order
+	for _, o := range s.orders {
+		if filter.Status != "" && o.Status != filter.Status {
+			continue
+		}
+		if filter.Customer != "" && !strings.EqualFold(o.Customer.Name, filter.Customer) {
+			continue
+		}
+		matches = append(matches, o)
+	}
+
+	// Map iteration order is random; sort so pagination is stable
+	slices.SortFunc(matches, func(a, b order) int {
+		return cmp.Or(a.CreatedAt.Compare(b.CreatedAt), strings.Compare(a.ID, b.ID))
+	})
+
+	total := len(matches)
+	start := min(filter.Offset, total)
+	end := total
+	if filter.Limit > 0 {
+		end = min(start+filter.Limit, total)
+	}
+	return matches[start:end], total, nil
+}
+
+func (s *memoryOrderStore) Get(_ context.Context, id string) (order, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	o, ok := s.orders[id]
+	if !ok {
+		return order{}, errOrderNotFound
+	}
+	return o, nil
+}
+
+func (s *memoryOrderStore) Create(ctx context.Context, o order) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := startWrite(ctx); err != nil {
+		return err
+	}
+
+	if _, exists := s.orders[o.ID]; exists {
+		return fmt.Errorf("order %s already exists", o.ID)
+	}
+	s.orders[o.ID] = o
+	return nil
+}
+
+func (s *memoryOrderStore) Update(ctx context.Context, id string, fn func(*order) error) (order, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := startWrite(ctx); err != nil {
+		return order{}, err
+	}
+
+	o, ok := s.orders[id]
+	if !ok {
+		return order{}, errOrderNotFound
+	}
+	// fn works on a copy, so a failed update leaves the stored order untouched
+	if err := fn(&o); err != nil {
+		return order{}, err
+	}
+	s.orders[id] = o
+	return o, nil
+}
+
+func (s *memoryOrderStore) Delete(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := startWrite(ctx); err != nil {
+		return err
+	}
+
+	if _, ok := s.orders[id]; !ok {
+		return errOrderNotFound
+	}
+	delete(s.orders, id)
+	return nil
+}
+
+// snapshot: Copy of every order, used by the file store to persist and roll back
+func (s *memoryOrderStore) snapshot() []order {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	all := make([]order, 0, len(s.orders))
+	for _, o := range s.orders {
+		all = append(all, o)
+	}
+	slices.SortFunc(all, func(a, b order) int { return strings.Compare(a.ID, b.ID) })
+	return all
+}
+
+// restore: Replaces the contents with all
+func (s *memoryOrderStore) restore(all []order) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.orders = make(map[string]order, len(all))
+	for _, o := range all {
+		s.orders[o.ID] = o
+	}
+}
+
+// fileOrderStore: The in-memory store, written to a JSON file after every change.
+// Good enough for a single instance; reads never touch the disk.
+type fileOrderStore struct {
+	*memoryOrderStore // Reads (List, Get) are served straight from memory
+
+	path string
+	mu   sync.Mutex // Serialises mutate-then-persist sequences
+}
+
+// newFileOrderStore: Loads path if it exists, otherwise starts empty (the file is created on first write)
+func newFileOrderStore(path string) (*fileOrderStore, error) {
+	s := &fileOrderStore{memoryOrderStore: newMemoryOrderStore(), path: path}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read orders file %s: %w", path, err)
+	}
+
+	var all []order
+	if err := json.Unmarshal(data, &all); err != nil {
+		return nil, fmt.Errorf("parse orders file %s: %w", path, err)
+	}
+	s.restore(all)
+	return s, nil
+}
+
+func (s *fileOrderStore) Create(ctx context.Context, o order) error {
+	return s.mutate(func() error { return s.memoryOrderStore.Create(ctx, o) })
+}
+
+func (s *fileOrderStore) Update(ctx context.Context, id string, fn func(*order) error) (order, error) {
+	var updated order
+	err := s.mutate(func() error {
+		var err error
+		updated, err = s.memoryOrderStore.Update(ctx, id, fn)
+		return err
+	})
+	return updated, err
+}
+
+func (s *fileOrderStore) Delete(ctx context.Context, id string) error {
+	return s.mutate(func() error { return s.memoryOrderStore.Delete(ctx, id) })
+}
+
+// mutate: Applies change in memory, then persists; if the write fails memory is rolled back
+func (s *fileOrderStore) mutate(change func() error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	before := s.snapshot()
+	if err := change(); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(s.snapshot(), "", "  ")
+	if err == nil {
+		err = writeFileAtomic(s.path, data, 0o600)
+	}
+	if err != nil {
+		s.restore(before)
+		return fmt.Errorf("persist orders to %s: %w", s.path, err)
+	}
+	return nil
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+)
+
+/**
+ * FUTURE REFERENCE: BEHIND A TLS-TERMINATING PROXY
+ * ------------------------------------------------
+ * When Envoy or nginx terminates TLS, our process never sees a handshake: r.TLS is nil and
+ * the proxy talks to us in cleartext HTTP/2 ("h2c"). The proxy forwards the client certificate
+ * it verified in a request header instead:
+ *   - Envoy: X-Forwarded-Client-Cert: By=...;Hash=...;Cert="<url-encoded PEM>";Chain="..."
+ *   - nginx: proxy_set_header X-Forwarded-Client-Cert $ssl_client_escaped_cert;  (url-encoded PEM)
+ * Anyone can send that header, so it is ONLY read from peers inside TRUSTED_PROXIES and stripped
+ * from everyone else. Even from a trusted proxy we verify the certificate again against our own
+ * client CA pool and revocation checks - the proxy proves key possession, we decide trust.
+ */
+
+// Listener modes
+const (
+	listenTLS = "tls" // We terminate TLS ourselves (default)
+	listenH2C = "h2c" // Cleartext HTTP/1.1 + HTTP/2 behind a proxy that terminates TLS
+)
+
+// defaultForwardedCertHeader: The header Envoy uses; nginx can be told to send the same one
+const defaultForwardedCertHeader = "X-Forwarded-Client-Cert"
+
+// forwardedCerts: Turns a trusted proxy's certificate header into a verified peer (see verifiedPeer)
+type forwardedCerts struct {
+	trusted []netip.Prefix
+	header  string
+
+	// roots: The current client CA pool (the reloader swaps it on rotation)
+	roots func() *x509.CertPool
+	// checkRevocation: The revocation check the TLS handshake runs after chain verification
+	checkRevocation func(verifiedChains [][]*x509.Certificate) error
+}
+
+// parseTrustedProxies: CIDRs ("10.0.0.0/8") or single addresses ("127.0.0.1")
+func parseTrustedProxies(entries []string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, entry := range entries {
+		if !strings.Contains(entry, "/") {
+			addr, err := netip.ParseAddr(entry)
+			if err != nil {
+				return nil, fmt.Errorf("trusted proxy %q: %w", entry, err)
+			}
+			prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(entry)
+		if err != nil {
+			return nil, fmt.Errorf("trusted proxy %q: %w", entry, err)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+// fromTrustedProxy: Reports whether the TCP peer (not any X-Forwarded-For claim) is a trusted proxy
+func (fc *forwardedCerts) fromTrustedProxy(r *http.Request) bool {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return false
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap() // "::ffff:10.0.0.1" must match "10.0.0.0/8"
+	for _, prefix := range fc.trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// middleware: Must run before everything that asks verifiedPeer (authz, rate limits, logs, metrics).
+// A forwarded certificate that does not verify is rejected, just like a bad one in a TLS handshake.
+func (fc *forwardedCerts) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		value := r.Header.Get(fc.header)
+		if value == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !fc.fromTrustedProxy(r) {
+			// Spoofed (or misconfigured proxy): never let handlers see it
+			r.Header.Del(fc.header)
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		chain, err := fc.verify(value)
+		if err != nil {
+			log.Printf("PROXY: rejected forwarded client certificate from %s: %v", r.RemoteAddr, err)
+			writeJSONError(w, http.StatusUnauthorized, "client_certificate_invalid",
+				"the forwarded client certificate could not be verified", nil)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), forwardedChainKey, chain)))
+	})
+}
+
+// verify: Parses the header and verifies the leaf the same way crypto/tls would
+func (fc *forwardedCerts) verify(value string) ([]*x509.Certificate, error) {
+	leaf, intermediates, err := parseForwardedCert(value)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := x509.VerifyOptions{
+		Roots:         fc.roots(),
+		Intermediates: x509.NewCertPool(),
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	for _, cert := range intermediates {
+		opts.Intermediates.AddCert(cert)
+	}
+	chains, err := leaf.Verify(opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := fc.checkRevocation(chains); err != nil {
+		return nil, err
+	}
+	return chains[0], nil
+}
+
+// parseForwardedCert: Accepts Envoy's XFCC format or a bare url-encoded PEM (nginx).
+// With several XFCC elements (one per proxy hop) the last one describes the client of the proxy we trust.
+func parseForwardedCert(value string) (*x509.Certificate, []*x509.Certificate, error) {
+	certPEM, chainPEM := value, ""
+	if !strings.HasPrefix(strings.TrimSpace(value), "-----BEGIN") {
+		elements := splitQuoted(value, ',')
+		fields := xfccFields(elements[len(elements)-1])
+		certPEM, chainPEM = fields["cert"], fields["chain"]
+		if certPEM == "" && chainPEM == "" {
+			return nil, nil, errors.New("header has no Cert or Chain field")
+		}
+	}
+
+	certs, err := decodeForwardedPEM(certPEM)
+	if err != nil {
+		return nil, nil, err
+	}
+	chain, err := decodeForwardedPEM(chainPEM)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Envoy's Chain starts with the leaf itself; Cert may be absent when only Chain is forwarded
+	certs = append(certs, chain...)
+	if len(certs) == 0 {
+		return nil, nil, errors.New("no certificate in header")
+	}
+	return certs[0], certs[1:], nil
+}
+
+// decodeForwardedPEM: URL-decodes and parses every CERTIFICATE block ("" yields nothing)
+func decodeForwardedPEM(value string) ([]*x509.Certificate, error) {
+	if value == "" {
+		return nil, nil
+	}
+	// PathUnescape, not QueryUnescape: a literal "+" in the base64 body must stay a "+"
+	raw, err := url.PathUnescape(value)
+	if err != nil {
+		return nil, fmt.Errorf("decode certificate: %w", err)
+	}
+
+	var certs []*x509.Certificate
+	rest := []byte(raw)
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("parse certificate: %w", err)
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, errors.New("no PEM certificate found")
+	}
+	return certs, nil
+}
+
+// xfccFields: Splits one XFCC element ("By=...;Cert=\"...\"") into lower-cased keys and unquoted values
+func xfccFields(element string) map[string]string {
+	fields := make(map[string]string)
+	for _, pair := range splitQuoted(element, ';') {
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"' {
+			value = strings.ReplaceAll(value[1:len(value)-1], `\"`, `"`)
+		}
+		fields[strings.ToLower(strings.TrimSpace(key))] = value
+	}
+	return fields
+}
+
+// splitQuoted: strings.Split that ignores separators inside double quotes (Subject="CN=a,OU=b")
+func splitQuoted(s string, sep byte) []string {
+	var parts []string
+	inQuotes, escaped, start := false, false, 0
+	for i := 0; i < len(s); i++ {
+		switch {
+		case escaped:
+			escaped = false
+		case s[i] == '\\':
+			escaped = true
+		case s[i] == '"':
+			inQuotes = !inQuotes
+		case s[i] == sep && !inQuotes:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+/**
+ * FUTURE REFERENCE: TOKEN BUCKET
+ * ------------------------------
+ * Every client identity owns a bucket holding up to Burst tokens that refills at Rate tokens
+ * per second. A request takes one token; an empty bucket means 429 Too Many Requests.
+ * Short bursts are fine, a sustained flood is capped at Rate requests/second.
+ * rateLimiter is an interface so several server instances can share buckets (e.g. in Redis);
+ * memoryRateLimiter keeps them in this process only.
+ */
+
+// quota: Rate is tokens per second, Burst the bucket size. Rate <= 0 means unlimited.
+type quota struct {
+	Rate  float64 `json:"rate"`
+	Burst int     `json:"burst"`
+}
+
+// rateLimitConfig: The quota file. Identities are keyed by client certificate CN.
+type rateLimitConfig struct {
+	Default    quota            `json:"default"`
+	Identities map[string]quota `json:"identities"`
+}
+
+// loadRateLimitConfig: Reads and validates a JSON quota file
+func loadRateLimitConfig(path string) (*rateLimitConfig, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("read rate limit config %s: %w", path, err)
+	}
+	defer f.Close()
+
+	var cfg rateLimitConfig
+	dec := json.NewDecoder(f)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&cfg); err != nil {
+		return nil, fmt.Errorf("parse rate limit config %s: %w", path, err)
+	}
+
+	check := func(name string, q quota) error {
+		if q.Rate > 0 && q.Burst < 1 {
+			return fmt.Errorf("rate limit config %s: %s: burst must be at least 1", path, name)
+		}
+		return nil
+	}
+	if err := check("default", cfg.Default); err != nil {
+		return nil, err
+	}
+	for cn, q := range cfg.Identities {
+		if err := check(cn, q); err != nil {
+			return nil, err
+		}
+	}
+	return &cfg, nil
+}
+
+// quotaFor: The identity's own quota, or the default
+func (cfg *rateLimitConfig) quotaFor(cn string) quota {
+	if q, ok := cfg.Identities[cn]; ok && cn != "" {
+		return q
+	}
+	return cfg.Default
+}
+
+// rateDecision: Outcome of taking one token
+type rateDecision struct {
+	Allowed    bool
+	Limit      int           // Bucket size
+	Remaining  int           // Whole tokens left after this request
+	RetryAfter time.Duration // When the next token is available (only meaningful if !Allowed)
+	ResetAfter time.Duration // When the bucket will be full again
+}
+
+// rateLimiter: A backend holding the buckets. Implementations must be safe for concurrent use.
+type rateLimiter interface {
+	Take(ctx context.Context, key string, q quota) (rateDecision, error)
+}
+
+// tokenBucket: State of one identity's bucket
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+	fullAt time.Time // When the bucket will have refilled to Burst (depends on the quota it was used with)
+}
+
+// memoryRateLimiter: Buckets in a map; only correct for a single server instance
+type memoryRateLimiter struct {
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+	now     func() time.Time
+}
+
+func newMemoryRateLimiter() *memoryRateLimiter {
+	return &memoryRateLimiter{buckets: make(map[string]*tokenBucket), now: time.Now}
+}
+
+func (l *memoryRateLimiter) Take(_ context.Context, key string, q quota) (rateDecision, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: float64(q.Burst), last: now}
+		l.buckets[key] = b
+	}
+
+	// Refill for the time since the last request, capped at the bucket size
+	b.tokens = math.Min(float64(q.Burst), b.tokens+now.Sub(b.last).Seconds()*q.Rate)
+	b.last = now
+
+	d := rateDecision{Limit: q.Burst}
+	if b.tokens >= 1 {
+		b.tokens--
+		d.Allowed = true
+	} else {
+		d.RetryAfter = secondsToDuration((1 - b.tokens) / q.Rate)
+	}
+	d.Remaining = int(b.tokens)
+	d.ResetAfter = secondsToDuration((float64(q.Burst) - b.tokens) / q.Rate)
+	b.fullAt = now.Add(d.ResetAfter)
+	return d, nil
+}
+
+// cleanup: Every interval, drops buckets that have been idle for at least idle AND are full again.
+// A fresh bucket starts full, so forgetting a full one changes nothing; with a slow quota
+// (e.g. rate 0.01/s, burst 20) that can take much longer than idle.
+func (l *memoryRateLimiter) cleanup(ctx context.Context, interval, idle time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			l.mu.Lock()
+			for key, b := range l.buckets {
+				if now := l.now(); now.Sub(b.last) > idle && !now.Before(b.fullAt) {
+					delete(l.buckets, key)
+				}
+			}
+			l.mu.Unlock()
+		}
+	}
+}
+
+// secondsToDuration: float seconds -> time.Duration
+func secondsToDuration(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+// rateLimitMiddleware: One bucket per client certificate CN; clients without a certificate
+// (per-route client auth mode) share buckets per remote IP under the default quota
+func rateLimitMiddleware(limiter rateLimiter, cfg *rateLimitConfig, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var key, cn string
+		if cert := verifiedPeer(r); cert != nil {
+			cn = cert.Subject.CommonName
+			key = "cn:" + cn
+		} else {
+			host, _, err := net.SplitHostPort(r.RemoteAddr)
+			if err != nil {
+				host = r.RemoteAddr
+			}
+			key = "ip:" + host
+		}
+
+		q := cfg.quotaFor(cn)
+		if q.Rate <= 0 {
+			next.ServeHTTP(w, r) // Unlimited
+			return
+		}
+
+		d, err := limiter.Take(r.Context(), key, q)
+		if err != nil {
+			// A broken shared backend should not take the whole API down with it: fail open
+			log.Printf("RATE LIMIT ERROR: allowing %s: %v", key, err)
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		h := w.Header()
+		h.Set("X-RateLimit-Limit", strconv.Itoa(d.Limit))
+		h.Set("X-RateLimit-Remaining", strconv.Itoa(d.Remaining))
+		h.Set("X-RateLimit-Reset", strconv.Itoa(ceilSeconds(d.ResetAfter)))
+
+		if !d.Allowed {
+			h.Set("Retry-After", strconv.Itoa(ceilSeconds(d.RetryAfter)))
+			writeJSONError(w, http.StatusTooManyRequests, "rate_limited", "request quota exceeded, retry later",
+				map[string]any{"key": key, "retry_after_seconds": ceilSeconds(d.RetryAfter)})
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ceilSeconds: Headers carry whole seconds; round up so clients never retry too early
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+/**
+ * FUTURE REFERENCE: HOT RELOAD LOGIC
+ * ----------------------------------
+ * ListenAndServeTLS(certFile, keyFile) reads the files exactly once, so rotating
+ * a certificate used to mean restarting the process (and dropping every HTTP/2 stream).
+ * Instead we keep the "current" certificate and CA pool behind atomic pointers and
+ * hand them to crypto/tls on every handshake through GetCertificate / GetConfigForClient.
+ * Existing connections keep the material they negotiated; new handshakes pick up the new files.
+ */
+
+// certReloader: Owns the server key pair and the client CA pool and swaps them atomically
+type certReloader struct {
+	certFile string
+	keyFile  string
+	caFile   string
+
+	cert      atomic.Pointer[tls.Certificate]
+	clientCAs atomic.Pointer[x509.CertPool]
+	caCerts   atomic.Pointer[[]*x509.Certificate] // The parsed CA bundle, for expiry monitoring
+
+	// staple: Optional hook that attaches an OCSP response to a freshly loaded certificate
+	staple func(*tls.Certificate) error
+
+	mu       sync.Mutex           // Serialises reloads (file watcher + SIGHUP may race)
+	modTimes map[string]time.Time // Last seen modification time of each watched file
+}
+
+// newCertReloader: Performs the first load. Unlike later reloads, a failure here is fatal for the caller.
+// staple may be nil when OCSP stapling is not wanted.
+func newCertReloader(certFile, keyFile, caFile string, staple func(*tls.Certificate) error) (*certReloader, error) {
+	cr := &certReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+		caFile:   caFile,
+		staple:   staple,
+		modTimes: make(map[string]time.Time),
+	}
+	if err := cr.reload(); err != nil {
+		return nil, err
+	}
+	return cr, nil
+}
+
+// reload: Reads all files and only publishes the new material if EVERYTHING parsed.
+// On error the previously loaded certificate and pool stay in place.
+func (cr *certReloader) reload() error {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+
+	cert, err := tls.LoadX509KeyPair(cr.certFile, cr.keyFile)
+	if err != nil {
+		return fmt.Errorf("load key pair %s/%s: %w", cr.certFile, cr.keyFile, err)
+	}
+
+	pool, err := loadClientCAs(cr.caFile)
+	if err != nil {
+		return err
+	}
+	caCerts, err := readCertificates(cr.caFile)
+	if err != nil {
+		return err
+	}
+
+	// A missing staple is not worth refusing a valid certificate; clients can still query OCSP themselves
+	if cr.staple != nil {
+		if err := cr.staple(&cert); err != nil {
+			log.Printf("OCSP STAPLE WARNING: serving %s without a staple: %v", cr.certFile, err)
+		}
+	}
+
+	cr.cert.Store(&cert)
+	cr.clientCAs.Store(pool)
+	cr.caCerts.Store(&caCerts)
+
+	// Remember what we loaded so the watcher does not reload the same files again
+	for _, path := range cr.files() {
+		if info, err := os.Stat(path); err == nil {
+			cr.modTimes[path] = info.ModTime()
+		}
+	}
+	return nil
+}
+
+// files: The list of paths the watcher keeps an eye on
+func (cr *certReloader) files() []string {
+	return []string{cr.certFile, cr.keyFile, cr.caFile}
+}
+
+// changed: Reports whether any watched file has a different modification time than the last load
+func (cr *certReloader) changed() bool {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+
+	for _, path := range cr.files() {
+		info, err := os.Stat(path)
+		if err != nil {
+			// A file that is missing mid-rotation counts as "changed"; reload() will report it
+			return true
+		}
+		if !info.ModTime().Equal(cr.modTimes[path]) {
+			return true
+		}
+	}
+	return false
+}
+
+// watch: Polls the files every interval and reloads on change or on SIGHUP, until ctx is cancelled.
+// When stapling is enabled the OCSP staple is also refreshed every stapleInterval.
+func (cr *certReloader) watch(ctx context.Context, interval, stapleInterval time.Duration) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	// A nil channel blocks forever, which disables the staple case when there is no stapler
+	var restaple <-chan time.Time
+	if cr.staple != nil {
+		stapleTicker := time.NewTicker(stapleInterval)
+		defer stapleTicker.Stop()
+		restaple = stapleTicker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			cr.reloadAndLog("SIGHUP")
+		case <-ticker.C:
+			if cr.changed() {
+				cr.reloadAndLog("file change")
+			}
+		case <-restaple:
+			cr.refreshStaple()
+		}
+	}
+}
+
+// refreshStaple: Fetches a new OCSP staple for the current certificate.
+// The published certificate is never mutated; a copy with the new staple replaces it.
+func (cr *certReloader) refreshStaple() {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+
+	cert := *cr.cert.Load()
+	if err := cr.staple(&cert); err != nil {
+		log.Printf("OCSP STAPLE WARNING: keeping previous staple: %v", err)
+		return
+	}
+	cr.cert.Store(&cert)
+}
+
+// reloadAndLog: Wraps reload() with the logging both triggers share
+func (cr *certReloader) reloadAndLog(trigger string) {
+	if err := cr.reload(); err != nil {
+		log.Printf("RELOAD ERROR (%s): keeping previous certificates: %v", trigger, err)
+		return
+	}
+	log.Printf("RELOAD: certificates and client CAs reloaded (%s)", trigger)
+}
+
+// GetCertificate: Plugged into tls.Config; returns whatever key pair is current
+func (cr *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return cr.cert.Load(), nil
+}
+
+// ClientCAs: The current client CA pool, for verifying certificates outside a handshake (see proxy.go)
+func (cr *certReloader) ClientCAs() *x509.CertPool {
+	return cr.clientCAs.Load()
+}
+
+// ServerCertificate: The parsed leaf of the current key pair (tls.LoadX509KeyPair fills Leaf)
+func (cr *certReloader) ServerCertificate() *x509.Certificate {
+	return cr.cert.Load().Leaf
+}
+
+// ClientCACerts: The certificates of the current client CA bundle
+func (cr *certReloader) ClientCACerts() []*x509.Certificate {
+	return *cr.caCerts.Load()
+}
+
+// GetConfigForClient: Returns a per-handshake copy of base that trusts the current client CA pool.
+// base must be the config the server was built with (it already carries GetCertificate).
+func (cr *certReloader) GetConfigForClient(base *tls.Config) func(*tls.ClientHelloInfo) (*tls.Config, error) {
+	return func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		cfg := base.Clone()
+		cfg.ClientCAs = cr.clientCAs.Load()
+		return cfg, nil
+	}
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+)
+
+// apiError: The JSON body every handler and middleware uses to report a failure
+type apiError struct {
+	Error   string `json:"error"`             // Machine readable code, e.g. "forbidden"
+	Message string `json:"message"`           // Human readable explanation
+	Details any    `json:"details,omitempty"` // Optional extra context (identity, route, ...)
+}
+
+// writeJSON: Serialises v as the response body with the given status code
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("RESPONSE ERROR: could not encode JSON body: %v", err)
+	}
+}
+
+// writeJSONError: Shortcut for writeJSON with an apiError body
+func writeJSONError(w http.ResponseWriter, status int, code, message string, details any) {
+	writeJSON(w, status, apiError{Error: code, Message: message, Details: details})
+}
@@ -0,0 +1,342 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/crypto/ocsp" // OCSP request/response encoding (not in the standard library)
+)
+
+/**
+ * FUTURE REFERENCE: REVOCATION LOGIC
+ * ----------------------------------
+ * Chain verification only proves a certificate WAS issued by our CA and has not expired.
+ * If a client key leaks we need a way to say "not this one anymore" before it expires:
+ *   - CRL:  the CA publishes a signed list of revoked serial numbers; we read it from disk.
+ *   - OCSP: we ask the CA's responder "is serial X still good?" over HTTP.
+ *   - OCSP stapling: the SERVER fetches its own OCSP answer and hands it to clients
+ *     during the handshake, so clients do not have to ask the responder themselves.
+ * For local testing point OCSP_RESPONDER at a stand-in, e.g.
+ *   openssl ocsp -port 8888 -index index.txt -CA ca.pem -rsigner ca.pem -rkey ca-key.pem
+ * revocation_test.go does the same in-process with an httptest responder and a generated CRL.
+ */
+
+// OCSP modes for client certificates
+const (
+	ocspOff  = "off"  // Never contact a responder
+	ocspSoft = "soft" // Reject revoked certs, allow the handshake if the responder is unreachable
+	ocspHard = "hard" // Reject revoked certs AND reject when no answer can be obtained
+)
+
+// parsedCRL: One CRL file, indexed by serial number for fast lookups
+type parsedCRL struct {
+	path    string
+	list    *x509.RevocationList
+	revoked map[string]time.Time // serial (decimal string) -> revocation time
+}
+
+// revocationChecker: Consulted by crypto/tls for every client certificate that passed chain verification
+type revocationChecker struct {
+	crlFiles      []string
+	crls          atomic.Pointer[[]*parsedCRL]
+	ocspMode      string
+	ocspResponder string // Overrides the responder URL embedded in certificates (AIA) when set
+	client        *http.Client
+
+	mu        sync.Mutex
+	ocspCache map[string]*ocsp.Response // issuer+serial -> last good/revoked answer, valid until NextUpdate
+}
+
+// newRevocationChecker: Loads the CRL files once; an unreadable CRL at startup is an error
+func newRevocationChecker(crlFiles []string, ocspMode, ocspResponder string) (*revocationChecker, error) {
+	switch ocspMode {
+	case "":
+		ocspMode = ocspOff
+	case ocspOff, ocspSoft, ocspHard:
+	default:
+		return nil, fmt.Errorf("unknown OCSP mode %q (want off, soft or hard)", ocspMode)
+	}
+
+	rc := &revocationChecker{
+		crlFiles:      crlFiles,
+		ocspMode:      ocspMode,
+		ocspResponder: ocspResponder,
+		client:        &http.Client{Timeout: 5 * time.Second},
+		ocspCache:     make(map[string]*ocsp.Response),
+	}
+	if err := rc.loadCRLs(); err != nil {
+		return nil, err
+	}
+	return rc, nil
+}
+
+// loadCRLs: Parses every configured CRL file. Like the certificate reloader, it is all-or-nothing.
+func (rc *revocationChecker) loadCRLs() error {
+	crls := make([]*parsedCRL, 0, len(rc.crlFiles))
+	for _, path := range rc.crlFiles {
+		crl, err := parseCRLFile(path)
+		if err != nil {
+			return err
+		}
+		if !crl.list.NextUpdate.IsZero() && time.Now().After(crl.list.NextUpdate) {
+			log.Printf("CRL WARNING: %s is stale (NextUpdate %s), publish a fresh one", path, crl.list.NextUpdate.Format(time.RFC3339))
+		}
+		crls = append(crls, crl)
+	}
+	rc.crls.Store(&crls)
+	return nil
+}
+
+// parseCRLFile: Accepts PEM ("X509 CRL" block) or raw DER
+func parseCRLFile(path string) (*parsedCRL, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read CRL %s: %w", path, err)
+	}
+	if block, _ := pem.Decode(data); block != nil {
+		data = block.Bytes
+	}
+
+	list, err := x509.ParseRevocationList(data)
+	if err != nil {
+		return nil, fmt.Errorf("parse CRL %s: %w", path, err)
+	}
+
+	crl := &parsedCRL{path: path, list: list, revoked: make(map[string]time.Time, len(list.RevokedCertificateEntries))}
+	for _, entry := range list.RevokedCertificateEntries {
+		crl.revoked[entry.SerialNumber.String()] = entry.RevocationTime
+	}
+	return crl, nil
+}
+
+// watch: Re-reads the CRL files every interval until ctx is cancelled
+func (rc *revocationChecker) watch(ctx context.Context, interval time.Duration) {
+	if len(rc.crlFiles) == 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := rc.loadCRLs(); err != nil {
+				log.Printf("CRL RELOAD ERROR: keeping previous lists: %v", err)
+			}
+		}
+	}
+}
+
+// VerifyConnection: Plugged into tls.Config. Unlike VerifyPeerCertificate, crypto/tls also calls it on
+// resumed sessions, so a certificate revoked after the first handshake cannot come back with a ticket.
+// It runs AFTER normal chain verification: VerifiedChains only holds chains that lead to one of our client CAs.
+func (rc *revocationChecker) VerifyConnection(cs tls.ConnectionState) error {
+	return rc.checkChains(cs.VerifiedChains)
+}
+
+// checkChains: Shared by the TLS hook and the forwarded-certificate check in proxy.go
+func (rc *revocationChecker) checkChains(verifiedChains [][]*x509.Certificate) error {
+	var lastErr error
+	for _, chain := range verifiedChains {
+		if lastErr = rc.checkChain(chain); lastErr == nil {
+			return nil // One clean path to a trusted root is enough
+		}
+	}
+	if lastErr != nil {
+		log.Printf("REVOCATION: rejecting client certificate: %v", lastErr)
+	}
+	return lastErr
+}
+
+// checkChain: Checks every certificate except the root against its issuer
+func (rc *revocationChecker) checkChain(chain []*x509.Certificate) error {
+	for i := 0; i+1 < len(chain); i++ {
+		cert, issuer := chain[i], chain[i+1]
+		if err := rc.checkCRLs(cert, issuer); err != nil {
+			return err
+		}
+		if err := rc.checkOCSP(cert, issuer); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkCRLs: Only CRLs issued (and signed) by the certificate's issuer are authoritative for it
+func (rc *revocationChecker) checkCRLs(cert, issuer *x509.Certificate) error {
+	crls := rc.crls.Load()
+	if crls == nil {
+		return nil
+	}
+	for _, crl := range *crls {
+		if !bytes.Equal(crl.list.RawIssuer, cert.RawIssuer) {
+			continue
+		}
+		if err := crl.list.CheckSignatureFrom(issuer); err != nil {
+			continue
+		}
+		if revokedAt, ok := crl.revoked[cert.SerialNumber.String()]; ok {
+			return fmt.Errorf("certificate CN=%q serial %s revoked at %s (%s)",
+				cert.Subject.CommonName, cert.SerialNumber, revokedAt.Format(time.RFC3339), crl.path)
+		}
+	}
+	return nil
+}
+
+// checkOCSP: Asks the responder (or the cache) about cert
+func (rc *revocationChecker) checkOCSP(cert, issuer *x509.Certificate) error {
+	if rc.ocspMode == ocspOff {
+		return nil
+	}
+
+	resp, err := rc.ocspStatus(cert, issuer)
+	if err != nil {
+		if rc.ocspMode == ocspHard {
+			return fmt.Errorf("OCSP check for CN=%q failed: %w", cert.Subject.CommonName, err)
+		}
+		log.Printf("OCSP WARNING: allowing CN=%q without an answer: %v", cert.Subject.CommonName, err)
+		return nil
+	}
+
+	switch resp.Status {
+	case ocsp.Revoked:
+		return fmt.Errorf("certificate CN=%q serial %s revoked at %s (OCSP)",
+			cert.Subject.CommonName, cert.SerialNumber, resp.RevokedAt.Format(time.RFC3339))
+	case ocsp.Unknown:
+		if rc.ocspMode == ocspHard {
+			return fmt.Errorf("OCSP responder does not know CN=%q serial %s", cert.Subject.CommonName, cert.SerialNumber)
+		}
+	}
+	return nil
+}
+
+// ocspStatus: Cached wrapper around fetchOCSP
+func (rc *revocationChecker) ocspStatus(cert, issuer *x509.Certificate) (*ocsp.Response, error) {
+	key := string(issuer.RawSubjectPublicKeyInfo) + "/" + cert.SerialNumber.String()
+
+	rc.mu.Lock()
+	cached, ok := rc.ocspCache[key]
+	ok = ok && time.Now().Before(cached.NextUpdate)
+	rc.mu.Unlock()
+	if ok {
+		return cached, nil
+	}
+
+	resp, _, err := rc.fetchOCSP(cert, issuer)
+	if err != nil {
+		return nil, err
+	}
+
+	// Responses without NextUpdate are not cacheable (the zero time is always in the past)
+	if now := time.Now(); now.Before(resp.NextUpdate) {
+		rc.mu.Lock()
+		// Sweep on insert: entries for certificates that stopped connecting are never looked up
+		// again, so waiting for a lookup of the same key would keep them forever
+		for k, cached := range rc.ocspCache {
+			if !now.Before(cached.NextUpdate) {
+				delete(rc.ocspCache, k)
+			}
+		}
+		rc.ocspCache[key] = resp
+		rc.mu.Unlock()
+	}
+	return resp, nil
+}
+
+// fetchOCSP: Performs one OCSP round trip and returns the parsed and raw (DER) answer
+func (rc *revocationChecker) fetchOCSP(cert, issuer *x509.Certificate) (*ocsp.Response, []byte, error) {
+	url := rc.ocspResponder
+	if url == "" {
+		if len(cert.OCSPServer) == 0 {
+			return nil, nil, errors.New("certificate has no OCSP responder and none is configured")
+		}
+		url = cert.OCSPServer[0]
+	}
+
+	req, err := ocsp.CreateRequest(cert, issuer, &ocsp.RequestOptions{Hash: crypto.SHA1})
+	if err != nil {
+		return nil, nil, fmt.Errorf("build OCSP request: %w", err)
+	}
+
+	httpResp, err := rc.client.Post(url, "application/ocsp-request", bytes.NewReader(req))
+	if err != nil {
+		return nil, nil, fmt.Errorf("contact OCSP responder %s: %w", url, err)
+	}
+	defer httpResp.Body.Close()
+
+	if httpResp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("OCSP responder %s returned %s", url, httpResp.Status)
+	}
+	raw, err := io.ReadAll(io.LimitReader(httpResp.Body, 1<<20))
+	if err != nil {
+		return nil, nil, fmt.Errorf("read OCSP response: %w", err)
+	}
+
+	// ParseResponseForCert also verifies the responder's signature against issuer
+	resp, err := ocsp.ParseResponseForCert(raw, cert, issuer)
+	if err != nil {
+		return nil, nil, fmt.Errorf("parse OCSP response from %s: %w", url, err)
+	}
+	return resp, raw, nil
+}
+
+// staple: Attaches a fresh OCSP response for the server's own certificate to cert.
+// Self-signed certificates (no issuer in the chain) have nothing to staple and are left alone.
+func (rc *revocationChecker) staple(cert *tls.Certificate) error {
+	if len(cert.Certificate) < 2 {
+		return nil
+	}
+
+	leaf := cert.Leaf
+	if leaf == nil {
+		var err error
+		if leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+			return fmt.Errorf("parse server certificate: %w", err)
+		}
+	}
+	issuer, err := x509.ParseCertificate(cert.Certificate[1])
+	if err != nil {
+		return fmt.Errorf("parse server issuer certificate: %w", err)
+	}
+
+	resp, raw, err := rc.fetchOCSP(leaf, issuer)
+	if err != nil {
+		return err
+	}
+	if resp.Status != ocsp.Good {
+		return fmt.Errorf("OCSP responder reports the server certificate as %s", ocspStatusName(resp.Status))
+	}
+
+	cert.OCSPStaple = raw
+	return nil
+}
+
+// Helper: Converts the ocsp package's status ints to readable strings
+func ocspStatusName(status int) string {
+	switch status {
+	case ocsp.Good:
+		return "good"
+	case ocsp.Revoked:
+		return "revoked"
+	case ocsp.Unknown:
+		return "unknown"
+	default:
+		return "invalid"
+	}
+}
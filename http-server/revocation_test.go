@@ -0,0 +1,317 @@
+package main
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+// testCA: A throwaway CA plus its key, built with the same helpers certgen uses
+func testCA(t *testing.T) (*x509.Certificate, crypto.Signer) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template, err := newTemplate(pkix.Name{CommonName: "Test CA"}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template.IsCA = true
+	template.BasicConstraintsValid = true
+	template.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature
+	ca, err := signCertificate(template, template, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ca, key
+}
+
+// testClientCert: A client certificate for cn signed by ca
+func testClientCert(t *testing.T, ca *x509.Certificate, caKey crypto.Signer, cn string) *x509.Certificate {
+	t.Helper()
+	return testKeyPair(t, ca, caKey, cn, x509.ExtKeyUsageClientAuth).Leaf
+}
+
+// testKeyPair: A leaf certificate for cn signed by ca, with its key, ready for a tls.Config
+func testKeyPair(t *testing.T, ca *x509.Certificate, caKey crypto.Signer, cn string, usage x509.ExtKeyUsage) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template, err := newTemplate(pkix.Name{CommonName: cn}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template.ExtKeyUsage = []x509.ExtKeyUsage{usage}
+	template.DNSNames = []string{cn}
+	cert, err := signCertificate(template, ca, key.Public(), caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{cert.Raw}, PrivateKey: key, Leaf: cert}
+}
+
+// writeTestCRL: A PEM CRL from ca revoking certs, written to a temp file
+func writeTestCRL(t *testing.T, ca *x509.Certificate, caKey crypto.Signer, certs ...*x509.Certificate) string {
+	t.Helper()
+	var entries []x509.RevocationListEntry
+	for _, cert := range certs {
+		entries = append(entries, x509.RevocationListEntry{SerialNumber: cert.SerialNumber, RevocationTime: time.Now().Add(-time.Minute)})
+	}
+	der, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+		Number:                    big.NewInt(time.Now().UnixNano()),
+		ThisUpdate:                time.Now().Add(-time.Minute),
+		NextUpdate:                time.Now().Add(time.Hour),
+		RevokedCertificateEntries: entries,
+	}, ca, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	crlPath := filepath.Join(t.TempDir(), "ca.crl")
+	if err := os.WriteFile(crlPath, pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: der}), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return crlPath
+}
+
+// ocspResponder: An httptest stand-in answering with the status in revoked (default good).
+// nextUpdate is added to the current time for every answer (0 = no NextUpdate).
+func ocspResponder(t *testing.T, ca *x509.Certificate, caKey crypto.Signer, revoked map[string]bool, nextUpdate time.Duration) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		body, _ := io.ReadAll(r.Body)
+		req, err := ocsp.ParseRequest(body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		now := time.Now()
+		template := ocsp.Response{
+			Status:       ocsp.Good,
+			SerialNumber: req.SerialNumber,
+			ThisUpdate:   now.Add(-time.Minute),
+		}
+		if nextUpdate != 0 {
+			template.NextUpdate = now.Add(nextUpdate)
+		}
+		if revoked[req.SerialNumber.String()] {
+			template.Status = ocsp.Revoked
+			template.RevokedAt = now.Add(-time.Hour)
+		}
+		der, err := ocsp.CreateResponse(ca, ca, template, caKey)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/ocsp-response")
+		w.Write(der)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &calls
+}
+
+func TestCRLRejectsRevokedClient(t *testing.T) {
+	ca, caKey := testCA(t)
+	good := testClientCert(t, ca, caKey, "good")
+	bad := testClientCert(t, ca, caKey, "bad")
+
+	rc, err := newRevocationChecker([]string{writeTestCRL(t, ca, caKey, bad)}, ocspOff, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rc.checkChains([][]*x509.Certificate{{good, ca}}); err != nil {
+		t.Errorf("good certificate rejected: %v", err)
+	}
+	if err := rc.checkChains([][]*x509.Certificate{{bad, ca}}); err == nil {
+		t.Error("revoked certificate accepted")
+	}
+}
+
+func TestOCSPRejectsRevokedClientAndCaches(t *testing.T) {
+	ca, caKey := testCA(t)
+	good := testClientCert(t, ca, caKey, "good")
+	bad := testClientCert(t, ca, caKey, "bad")
+	srv, calls := ocspResponder(t, ca, caKey, map[string]bool{bad.SerialNumber.String(): true}, time.Hour)
+
+	rc, err := newRevocationChecker(nil, ocspHard, srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rc.checkChains([][]*x509.Certificate{{good, ca}}); err != nil {
+		t.Errorf("good certificate rejected: %v", err)
+	}
+	if err := rc.checkChains([][]*x509.Certificate{{bad, ca}}); err == nil {
+		t.Error("revoked certificate accepted")
+	}
+	if err := rc.checkChains([][]*x509.Certificate{{good, ca}}); err != nil {
+		t.Errorf("good certificate rejected on the cached answer: %v", err)
+	}
+	if got := calls.Load(); got != 2 {
+		t.Errorf("responder called %d times, want 2 (second check of good should be cached)", got)
+	}
+}
+
+func TestOCSPUnreachableResponder(t *testing.T) {
+	ca, caKey := testCA(t)
+	cert := testClientCert(t, ca, caKey, "client")
+	srv := httptest.NewServer(http.NotFoundHandler())
+	srv.Close() // Nothing listens on this address any more
+
+	for mode, wantErr := range map[string]bool{ocspSoft: false, ocspHard: true} {
+		rc, err := newRevocationChecker(nil, mode, srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = rc.checkChains([][]*x509.Certificate{{cert, ca}})
+		if (err != nil) != wantErr {
+			t.Errorf("mode %s: err = %v, want error %v", mode, err, wantErr)
+		}
+	}
+}
+
+func TestOCSPCacheSweepsStaleEntries(t *testing.T) {
+	ca, caKey := testCA(t)
+	gone := testClientCert(t, ca, caKey, "gone")
+	cert := testClientCert(t, ca, caKey, "client")
+	srv, _ := ocspResponder(t, ca, caKey, nil, time.Hour)
+
+	rc, err := newRevocationChecker(nil, ocspHard, srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// An answer for a certificate that never connects again: no lookup of its key will come
+	goneKey := string(ca.RawSubjectPublicKeyInfo) + "/" + gone.SerialNumber.String()
+	rc.ocspCache[goneKey] = &ocsp.Response{Status: ocsp.Good, NextUpdate: time.Now().Add(-time.Second)}
+
+	if _, err := rc.ocspStatus(cert, ca); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := rc.ocspCache[goneKey]; ok {
+		t.Errorf("stale entry of another certificate survived an insert")
+	}
+	if len(rc.ocspCache) != 1 {
+		t.Errorf("cache holds %d entries, want 1 (the fresh answer)", len(rc.ocspCache))
+	}
+}
+
+func TestRevocationCheckedOnResumedSessions(t *testing.T) {
+	ca, caKey := testCA(t)
+	serverCert := testKeyPair(t, ca, caKey, "localhost", x509.ExtKeyUsageServerAuth)
+	clientCert := testKeyPair(t, ca, caKey, "client", x509.ExtKeyUsageClientAuth)
+
+	crlPath := writeTestCRL(t, ca, caKey) // Nothing revoked yet
+	rc, err := newRevocationChecker([]string{crlPath}, ocspOff, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca)
+	serverConfig := &tls.Config{
+		Certificates:     []tls.Certificate{serverCert},
+		ClientAuth:       tls.RequireAndVerifyClientCert,
+		ClientCAs:        pool,
+		VerifyConnection: rc.VerifyConnection,
+	}
+	clientConfig := &tls.Config{
+		Certificates:       []tls.Certificate{clientCert},
+		RootCAs:            pool,
+		ServerName:         "localhost",
+		ClientSessionCache: tls.NewLRUClientSessionCache(1),
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	results := make(chan error)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			tlsConn := tls.Server(conn, serverConfig)
+			err = tlsConn.Handshake()
+			if err == nil {
+				_, err = tlsConn.Write([]byte("x")) // Lets the client read the session ticket
+			}
+			tlsConn.Close()
+			results <- err
+		}
+	}()
+
+	// connect: One handshake; reports whether the client resumed and what the server decided
+	connect := func() (bool, error) {
+		conn, err := tls.Dial("tcp", ln.Addr().String(), clientConfig)
+		if err != nil {
+			return false, <-results
+		}
+		defer conn.Close()
+		conn.Read(make([]byte, 1))
+		return conn.ConnectionState().DidResume, <-results
+	}
+
+	if _, err := connect(); err != nil {
+		t.Fatalf("first handshake: %v", err)
+	}
+	if resumed, err := connect(); !resumed || err != nil {
+		t.Fatalf("second handshake: resumed %v, err %v; want a resumed session", resumed, err)
+	}
+
+	revoked := writeTestCRL(t, ca, caKey, clientCert.Leaf)
+	if err := os.Rename(revoked, crlPath); err != nil {
+		t.Fatal(err)
+	}
+	if err := rc.loadCRLs(); err != nil {
+		t.Fatal(err)
+	}
+	quietLog(t)
+
+	if _, err := connect(); err == nil {
+		t.Error("revoked certificate accepted on a resumed session")
+	}
+}
+
+func TestOCSPCacheIgnoresStaleEntries(t *testing.T) {
+	ca, caKey := testCA(t)
+	cert := testClientCert(t, ca, caKey, "client")
+	srv, calls := ocspResponder(t, ca, caKey, nil, 0) // Answers without NextUpdate are not cacheable
+
+	rc, err := newRevocationChecker(nil, ocspHard, srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := string(ca.RawSubjectPublicKeyInfo) + "/" + cert.SerialNumber.String()
+	rc.ocspCache[key] = &ocsp.Response{Status: ocsp.Good, NextUpdate: time.Now().Add(-time.Second)}
+
+	if _, err := rc.ocspStatus(cert, ca); err != nil {
+		t.Fatal(err)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("stale entry served without asking the responder")
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+)
+
+/**
+ * FUTURE REFERENCE: ROUTER
+ * ------------------------
+ * http.HandleFunc registers on the global http.DefaultServeMux, which any imported package
+ * can also write to and which tests cannot replace. The router owns a private ServeMux and
+ * adds what the server needs on top of Go's pattern matching ("GET /orders/{id}"):
+ *   - Groups:      a path prefix plus middleware shared by every route in the group
+ *   - JSON errors: 404, and 405 with the Allow header the mux computes, as apiError bodies
+ */
+
+// middleware: Wraps a handler (authorization, certificate requirements, ...)
+type middleware func(http.Handler) http.Handler
+
+// router: A ServeMux shared by every group created from it
+type router struct {
+	mux        *http.ServeMux
+	prefix     string
+	middleware []middleware
+}
+
+func newRouter() *router {
+	return &router{mux: http.NewServeMux()}
+}
+
+// Use: Adds middleware to every route registered on this group AFTER the call
+func (rt *router) Use(mw ...middleware) {
+	rt.middleware = append(rt.middleware, mw...)
+}
+
+// Group: A sub-router under prefix; it inherits the parent's middleware and adds its own
+func (rt *router) Group(prefix string, mw ...middleware) *router {
+	return &router{
+		mux:        rt.mux,
+		prefix:     rt.prefix + prefix,
+		middleware: append(slices.Clone(rt.middleware), mw...),
+	}
+}
+
+// Handle: Registers h for method (empty = any method) and path (may contain {params}, see http.ServeMux)
+func (rt *router) Handle(method, path string, h http.Handler) {
+	pattern := rt.prefix + path
+	if pattern == "" {
+		pattern = "/"
+	}
+	if method != "" {
+		pattern = method + " " + pattern
+	}
+
+	// The first middleware is the outermost
+	for i := len(rt.middleware) - 1; i >= 0; i-- {
+		h = rt.middleware[i](h)
+	}
+	rt.mux.Handle(pattern, h)
+}
+
+// HandleFunc: Handle for plain functions
+func (rt *router) HandleFunc(method, path string, h http.HandlerFunc) {
+	rt.Handle(method, path, h)
+}
+
+// route: The pattern a request matches ("" when nothing does); bounded, so safe as a metrics label
+func (rt *router) route(r *http.Request) string {
+	_, pattern := rt.mux.Handler(r)
+	return pattern
+}
+
+// ServeHTTP: Dispatches to the matching route, answering misses with JSON errors
+func (rt *router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h, pattern := rt.mux.Handler(r)
+	if pattern != "" {
+		rt.mux.ServeHTTP(w, r) // Let the mux set r.Pattern and the {params} for PathValue
+		return
+	}
+	// No route: the mux's own handler answers 404, 405 (+ Allow) or a redirect; rewrite the errors as JSON
+	h.ServeHTTP(&routeMissWriter{ResponseWriter: w, r: r}, r)
+}
+
+// routeMissWriter: Replaces the plain text 404/405 bodies of http.ServeMux with apiError JSON
+type routeMissWriter struct {
+	http.ResponseWriter
+	r        *http.Request
+	replaced bool
+}
+
+func (mw *routeMissWriter) WriteHeader(status int) {
+	switch status {
+	case http.StatusNotFound:
+		mw.replaced = true
+		writeJSONError(mw.ResponseWriter, status, "not_found", "no route for this path", map[string]string{"path": mw.r.URL.Path})
+	case http.StatusMethodNotAllowed:
+		mw.replaced = true
+		writeJSONError(mw.ResponseWriter, status, "method_not_allowed", "method not allowed for this path",
+			map[string]string{"method": mw.r.Method, "path": mw.r.URL.Path, "allow": mw.Header().Get("Allow")})
+	default:
+		mw.ResponseWriter.WriteHeader(status)
+	}
+}
+
+func (mw *routeMissWriter) Write(p []byte) (int, error) {
+	if mw.replaced {
+		return len(p), nil // Drop the mux's plain text body
+	}
+	return mw.ResponseWriter.Write(p)
+}
@@ -1,14 +1,25 @@
 package main
 
 import (
+	"cmp"
+	"context"
 	"crypto/tls"   // Core package for Transport Layer Security
 	"crypto/x509"  // Package for parsing X.509 certificates (needed for mTLS)
+	"errors"
+	"flag"
 	"fmt"
 	"log"
+	"log/slog"
 	"net/http"     // Standard library for HTTP servers
 	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 
-	"golang.org/x/net/http2" // Support for HTTP/2 features
+	"github.com/quic-go/quic-go/http3" // HTTP/3 over QUIC (see http3.go)
+	"golang.org/x/net/http2"           // Support for HTTP/2 features
+	"golang.org/x/net/http2/h2c"       // Cleartext HTTP/2 behind a TLS-terminating proxy
 )
 
 /**
@@ -20,101 +31,387 @@ import (
  */
 
 // loadClientCAs: Setup the "Trust Store"
-func loadClientCAs(caPath string) *x509.CertPool {
+// Returns an error instead of exiting so a bad file during a hot reload does not kill the server.
+func loadClientCAs(caPath string) (*x509.CertPool, error) {
 	// 1. Create an empty Certificate Pool
 	clientCAs := x509.NewCertPool()
 
 	// 2. Read the Certificate Authority (CA) file from disk
 	caCert, err := os.ReadFile(caPath)
 	if err != nil {
-		log.Fatalf("FILE ERROR: Cannot find %s. Did you run the openssl command? %v", caPath, err)
+		return nil, fmt.Errorf("FILE ERROR: Cannot find %s. Run `go run . certgen ca` to create a dev CA. %w", caPath, err)
 	}
 
 	// 3. Parse the PEM encoded data and add it to our pool of trusted certs
 	ok := clientCAs.AppendCertsFromPEM(caCert)
 	if !ok {
-		log.Fatal("PARSE ERROR: cert.pem exists but is not a valid PEM certificate.")
+		return nil, errors.New("PARSE ERROR: " + caPath + " exists but is not a valid PEM certificate.")
 	}
 
-	return clientCAs
+	return clientCAs, nil
 }
 
 func main() {
+	// --- SUBCOMMANDS ---
+
+	// `go run . certgen ...` builds a dev PKI instead of starting the server
+	if len(os.Args) > 1 && os.Args[1] == "certgen" {
+		if err := runCertgen(os.Args[2:]); err != nil {
+			log.Fatalln("CERTGEN ERROR:", err)
+		}
+		return
+	}
+
+	// --- CONFIGURATION ---
+
+	// defaults < config file < env vars < flags (see config.go and config.example.json)
+	cfg, printOnly, err := loadConfig(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return // -h: the flag package already printed the usage
+	}
+	if err != nil {
+		log.Fatalln("CONFIG ERROR:", err)
+	}
+
+	// The CA bundle used to verify clients (created by `go run . certgen ca`).
+	// Older setups with a single self-signed cert.pem keep working: it then doubles as the CA.
+	caFallback := false
+	if cfg.TLS.ClientCAFile == "" {
+		cfg.TLS.ClientCAFile = "ca.pem"
+		if _, err := os.Stat(cfg.TLS.ClientCAFile); errors.Is(err, os.ErrNotExist) {
+			cfg.TLS.ClientCAFile, caFallback = cfg.TLS.CertFile, true
+		}
+	}
+
+	if printOnly {
+		if err := printConfig(cfg); err != nil {
+			log.Fatalln("CONFIG ERROR:", err)
+		}
+		return
+	}
+	if caFallback {
+		fmt.Printf("Note: ca.pem not found, trusting %s as the client CA (run `go run . certgen ca`).\n", cfg.TLS.CertFile)
+	}
+
 	// --- ROUTING ---
 
-	// Default Route: Tests if the server is alive
-	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		logRequestDetails(r)
+	// A dedicated router instead of the global http.DefaultServeMux (see router.go).
+	// Groups decide whether a client certificate is needed (see clientauth.go).
+	routes := newRouter()
+	public := routes.Group("", requireCert(certOptional))
+	protected := routes.Group("", requireCert(certRequired))
+
+	// Default Route: Tests if the server is alive. "{$}" matches "/" exactly, so unknown paths get a 404.
+	// The certificate is optional so load balancer health checks work in per-route client auth mode.
+	public.HandleFunc(http.MethodGet, "/{$}", func(w http.ResponseWriter, r *http.Request) {
+		if verifiedPeer(r) == nil {
+			fmt.Fprintln(w, "Connected via TLS (no client certificate presented).")
+			return
+		}
 		fmt.Fprintln(w, "Auth Success: You are connected via mTLS!")
 	})
 
-	// Secondary Route: Example of a protected resource
-	http.HandleFunc("/orders", func(w http.ResponseWriter, r *http.Request) {
-		logRequestDetails(r)
-		fmt.Fprintln(w, "Access Granted: Viewing secure order data.")
-	})
+	// Orders REST resource: kept in memory, or in a JSON file when ORDERS_FILE is set
+	var store orderStore = newMemoryOrderStore()
+	if cfg.OrdersFile != "" {
+		fileStore, err := newFileOrderStore(cfg.OrdersFile)
+		if err != nil {
+			log.Fatalln("STARTUP ERROR:", err)
+		}
+		store = fileStore
+	}
+	orders := &ordersAPI{store: store}
+	orders.register(protected.Group("/orders"))
+
+	// Health and TLS introspection: no certificate needed, so probes and confused clients can reach them
+	health := newHealthChecker(2 * time.Second)
+	health.add("storage", orderStoreCheck(store))
+	if cfg.RabbitMQAddr != "" {
+		health.add("rabbitmq", tcpCheck(cfg.RabbitMQAddr))
+	}
+	public.HandleFunc(http.MethodGet, "/healthz", health.liveness)
+	public.HandleFunc(http.MethodGet, "/readyz", health.readiness)
+	public.HandleFunc(http.MethodGet, "/debug/tls", debugTLS)
+
+	// --- LOGGING ---
+
+	// Structured logs (json or logfmt). SetDefault also routes the log.Printf
+	// calls used throughout the server through the same handler.
+	logger := newLogger(cfg.LogFormat)
+	slog.SetDefault(logger)
 
 	// --- TLS & SERVER CONFIGURATION ---
 
-	const port = "3000"
-	certFile := "cert.pem" // Path to the public certificate
-	keyFile := "key.pem"   // Path to the private key (Keep this secret!)
+	// SIGINT / SIGTERM cancel this context: background watchers stop and the server drains
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// How long in-flight requests get to finish once a shutdown signal arrives
+	drainTimeout := cfg.Timeouts.Shutdown.Duration
+
+	// Revocation: CRL files re-read every minute, plus optional OCSP
+	revocation, err := newRevocationChecker(cfg.Revocation.CRLFiles, cfg.Revocation.OCSPMode, cfg.Revocation.OCSPResponder)
+	if err != nil {
+		log.Fatalln("STARTUP ERROR:", err)
+	}
+	go revocation.watch(ctx, time.Minute)
+
+	// OCSP stapling for our own certificate is opt-in (it needs a responder that knows our CA)
+	var staple func(*tls.Certificate) error
+	if *cfg.Revocation.OCSPStaple {
+		staple = revocation.staple
+	}
+
+	// Load the key pair and CA pool once up front, then keep watching them for rotation
+	reloader, err := newCertReloader(cfg.TLS.CertFile, cfg.TLS.KeyFile, cfg.TLS.ClientCAFile, staple)
+	if err != nil {
+		log.Fatalln("STARTUP ERROR:", err)
+	}
+	go reloader.watch(ctx, 5*time.Second, time.Hour)
+
+	// Warn well before the server certificate, the client CAs or a client's certificate expire (see expiry.go)
+	expiry := newExpiryMonitor(cfg.Expiry.WarnBefore.Duration, reloader.ServerCertificate, reloader.ClientCACerts)
+	go expiry.watch(ctx, cfg.Expiry.CheckInterval.Duration)
+
+	// Versions, cipher suites, curves and ALPN come from a named profile (see tlsprofile.go)
+	profile, err := buildTLSProfile(cfg.TLS.tlsSettings)
+	if err != nil {
+		log.Fatalln("STARTUP ERROR:", err)
+	}
+
+	// tls (default) or h2c behind a TLS-terminating proxy (see proxy.go)
+	listenMode := cfg.Listen.Mode
+
+	// require (default) or per-route (see clientauth.go)
+	clientAuthMode := cfg.TLS.ClientAuth
+	clientAuth, err := tlsClientAuth(clientAuthMode)
+	if err != nil {
+		log.Fatalln("STARTUP ERROR:", err)
+	}
 
 	// Define the security rules for the connection
 	tlsConfig := &tls.Config{
-		// Force modern security; TLS 1.0 and 1.1 are considered insecure/deprecated
-		MinVersion: tls.VersionTLS12,
-
 		// REQUIRE: The server will reject any client that does not send a certificate
+		//          (per-route mode: the handshake accepts it and each route decides)
 		// VERIFY: The server will check the client certificate against the 'ClientCAs' pool
-		ClientAuth: tls.RequireAndVerifyClientCert,
+		ClientAuth: clientAuth,
+
+		// REVOKED: Runs after chain verification on every handshake (resumed ones too) and rejects
+		// certificates listed in a CRL or by OCSP
+		VerifyConnection: revocation.VerifyConnection,
+
+		// The server certificate is looked up on every handshake so a reload takes effect immediately
+		GetCertificate: reloader.GetCertificate,
+	}
+
+	// TLS 1.0 and 1.1 are never allowed; the per-handshake clone below inherits all of this
+	profile.apply(tlsConfig)
+
+	// The list of Root CAs that the server uses to verify client identities.
+	// It is swapped in per handshake (instead of a fixed ClientCAs field) so CA rotation needs no restart.
+	tlsConfig.GetConfigForClient = reloader.GetConfigForClient(tlsConfig)
+
+	// --- AUTHORIZATION ---
+
+	// Which client identities may call which routes (see policy.example.json).
+	// Without a policy every verified client can reach every handler.
+	var handler http.Handler = routes // The handlers registered above
+
+	// Without a handshake nobody enforces "require", so in h2c mode every route needs a forwarded certificate
+	if listenMode == listenH2C && clientAuthMode == clientAuthRequire {
+		handler = requireCert(certRequired)(handler)
+	}
+
+	if policyFile := cfg.AuthzPolicyFile; policyFile != "" {
+		policy, err := loadAuthzPolicy(policyFile)
+		if err != nil {
+			log.Fatalln("STARTUP ERROR:", err)
+		}
+		handler = policy.middleware(handler)
+		fmt.Printf("Authorization policy loaded from %s (%d rules)\n", policyFile, len(policy.Rules))
+	} else {
+		fmt.Println("Note: no authorization policy file set, every verified client may access every route.")
+	}
 
-		// The list of Root CAs that the server uses to verify client identities
-		ClientCAs: loadClientCAs(certFile),
+	// --- RATE LIMITING ---
 
-		// Optimization: Server chooses the fastest/most secure cipher common to both parties
-		PreferServerCipherSuites: true,
+	// Token buckets per client CN (see ratelimit.go). Runs before authorization so denied
+	// requests count against the quota as well.
+	if rateLimitFile := cfg.RateLimitFile; rateLimitFile != "" {
+		quotas, err := loadRateLimitConfig(rateLimitFile)
+		if err != nil {
+			log.Fatalln("STARTUP ERROR:", err)
+		}
+		limiter := newMemoryRateLimiter()
+		go limiter.cleanup(ctx, time.Minute, 10*time.Minute)
+		handler = rateLimitMiddleware(limiter, quotas, handler)
+		fmt.Printf("Rate limits loaded from %s (%d identities with own quota)\n", rateLimitFile, len(quotas.Identities))
+	}
+
+	// --- LIMITS ---
+
+	// Body size and handler deadline per request (see limits.go); connection timeouts are set on the server below
+	handler = routeTimeouts{
+		def:      cfg.Limits.HandlerTimeout.Duration,
+		perRoute: durations(cfg.Limits.RouteTimeouts),
+		routeOf:  routes.route,
+	}.middleware(handler)
+	handler = limitBody(cfg.Limits.MaxBodyBytes, handler)
+
+	// A panicking handler answers a JSON 500; runs inside the metrics and the access log so both record it
+	handler = recoverPanics(handler)
+
+	// --- OBSERVABILITY ---
+
+	// Per-route counters and latency. The route label is the registered pattern, never the raw path.
+	inFlight := &inFlightTracker{}
+	metrics := newServerMetrics(inFlight, routes.route, expiry)
+	handler = metrics.middleware(handler)
+
+	// Remember which client certificate every CN connects with, for /certs/expiring
+	handler = expiry.middleware(handler)
+
+	// One access log record per request, including the ones authorization rejected
+	handler = accessLog(logger, handler)
+
+	// Behind a proxy: turn the forwarded client certificate into a verified peer before anything asks for it
+	if listenMode == listenH2C {
+		trusted, err := parseTrustedProxies(cfg.Listen.TrustedProxies)
+		if err != nil {
+			log.Fatalln("STARTUP ERROR:", err)
+		}
+		forwarded := &forwardedCerts{
+			trusted:         trusted,
+			header:          cfg.Listen.ForwardedCertHeader,
+			roots:           reloader.ClientCAs,
+			checkRevocation: revocation.checkChains,
+		}
+		handler = forwarded.middleware(handler)
+		if len(trusted) == 0 {
+			fmt.Println("Note: no trusted proxies configured, forwarded client certificates are ignored.")
+		}
+	}
+
+	// Outermost: count in-flight requests so shutdown can report how many it had to cut off
+	handler = inFlight.middleware(handler)
+
+	// Optional HTTP/3 (QUIC) listener (e.g. ":3000", UDP), same TLS config and handlers.
+	// Responses over TCP advertise it with an Alt-Svc header.
+	var h3 *http3.Server
+	if cfg.Listen.HTTP3Addr != "" {
+		h3, err = newHTTP3Server(cfg.Listen.HTTP3Addr, tlsConfig, nil)
+		if err != nil {
+			log.Fatalln("STARTUP ERROR:", err)
+		}
+		handler = advertiseHTTP3(h3, handler)
+		h3.Handler = handler
 	}
 
 	// Initialize the Server object with our custom security settings
 	server := &http.Server{
-		Addr:      ":" + port,
-		TLSConfig: tlsConfig,
-		Handler:   nil, // Use http.DefaultServeMux (the handlers registered above)
+		Addr:              cfg.Listen.Addr,
+		TLSConfig:         tlsConfig,
+		Handler:           handler,
+		ErrorLog:          metrics.serverErrorLog(), // Counts TLS handshake failures by reason
+		ReadHeaderTimeout: cfg.Timeouts.ReadHeader.Duration,
+		ReadTimeout:       cfg.Timeouts.Read.Duration,
+		WriteTimeout:      cfg.Timeouts.Write.Duration,
+		IdleTimeout:       cfg.Timeouts.Idle.Duration,
+		MaxHeaderBytes:    cfg.MaxHeaderBytes,
 	}
 
-	// Explicitly enable HTTP/2 support (required for modern high-performance Go apps)
-	if err := http2.ConfigureServer(server, &http2.Server{}); err != nil {
-		log.Fatalf("H2 ERROR: Could not configure HTTP/2: %v", err)
+	// Admin listener for /metrics: plaintext on localhost unless admin TLS is on (then it requires mTLS too)
+	adminMux := http.NewServeMux()
+	adminMux.Handle("GET /metrics", metrics)
+	adminMux.HandleFunc("GET /healthz", health.liveness)
+	adminMux.HandleFunc("GET /readyz", health.readinessDetails)
+	adminMux.Handle("GET /certs/expiring", expiry)
+	var adminTLS *tls.Config
+	if *cfg.Admin.TLS {
+		adminTLS = tlsConfig
 	}
+	admin := newAdminServer(cfg.Admin.Addr, adminTLS, adminMux)
 
-	fmt.Printf("🚀 mTLS Server active at https://localhost:%s\n", port)
-	fmt.Println("Note: Clients must provide a valid certificate to connect.")
+	// Explicitly enable HTTP/2 support (required for modern high-performance Go apps).
+	// Stream and frame limits come from the config; zero keeps the x/net defaults.
+	// A profile without "h2" in its ALPN list gets an empty TLSNextProto map, which disables HTTP/2.
+	// In h2c mode there is no ALPN: h2c.NewHandler accepts HTTP/2 with prior knowledge or via "Upgrade: h2c".
+	h2 := &http2.Server{
+		MaxConcurrentStreams: cfg.HTTP2.MaxConcurrentStreams,
+		MaxReadFrameSize:     cfg.HTTP2.MaxReadFrameSize,
+		IdleTimeout:          cmp.Or(cfg.HTTP2.IdleTimeout.Duration, cfg.Timeouts.Idle.Duration),
+	}
+	if listenMode == listenH2C {
+		// ConfigureServer registers the shutdown hook that sends GOAWAY, which h2c connections need too
+		// (they are hijacked, so server.Shutdown does not see them otherwise). It sets a TLSConfig we do not use.
+		if err := http2.ConfigureServer(server, h2); err != nil {
+			log.Fatalf("H2 ERROR: Could not configure HTTP/2: %v", err)
+		}
+		server.TLSConfig = nil
+		server.Handler = h2c.NewHandler(handler, h2)
+	} else if profile.http2Enabled() {
+		if err := http2.ConfigureServer(server, h2); err != nil {
+			log.Fatalf("H2 ERROR: Could not configure HTTP/2: %v", err)
+		}
+	} else {
+		server.TLSNextProto = map[string]func(*http.Server, *tls.Conn, http.Handler){}
+	}
 
-	// Start the server using the cert and key generated by your OpenSSL command
-	err := server.ListenAndServeTLS(certFile, keyFile)
-	if err != nil {
-		log.Fatalln("STARTUP ERROR: Server failed to start:", err)
+	if listenMode == listenH2C {
+		fmt.Printf("🚀 h2c Server active at http://%s (TLS terminated by the proxy)\n", displayAddr(server.Addr))
+	} else {
+		fmt.Printf("🚀 mTLS Server active at https://%s\n", displayAddr(server.Addr))
 	}
-}
+	if clientAuthMode == clientAuthPerRoute {
+		fmt.Println("Note: Client certificates are verified if given; each route decides whether one is required.")
+	} else {
+		fmt.Println("Note: Clients must provide a valid certificate to connect.")
+	}
+	fmt.Println(profile.report())
+	fmt.Println("Note: Certificates are reloaded on file change or SIGHUP.")
+	fmt.Printf("Admin endpoints (/metrics, /healthz, /readyz, /certs/expiring) at %s\n", admin.Addr)
 
-// logRequestDetails: Inspects the "Handshake" to see what security was used
-func logRequestDetails(r *http.Request) {
-	// Proto shows "HTTP/2.0" or "HTTP/1.1"
-	fmt.Printf("[%s] %s Request\n", r.Method, r.Proto)
+	serveAdmin(admin)
+	if h3 != nil {
+		fmt.Printf("⚡ HTTP/3 (QUIC) listener active at udp %s\n", h3.Addr)
+		serveHTTP3(h3)
+	}
 
-	if r.TLS != nil {
-		// Log the version (e.g., TLS 1.3)
-		versionName := getTLSVersionName(r.TLS.Version)
-		fmt.Printf("  -> Encryption: %s\n", versionName)
+	// Start the server; empty paths because GetCertificate serves the (reloadable) key pair.
+	// It runs in the background so main can wait for a shutdown signal.
+	serveErr := make(chan error, 1)
+	go func() {
+		if listenMode == listenH2C {
+			serveErr <- server.ListenAndServe()
+			return
+		}
+		serveErr <- server.ListenAndServeTLS("", "")
+	}()
 
-		// Check the client's identity (the 'Common Name' or CN)
-		if len(r.TLS.PeerCertificates) > 0 {
-			clientCN := r.TLS.PeerCertificates[0].Subject.CommonName
-			fmt.Printf("  -> Client ID (CN): %s\n", clientCN)
+	select {
+	case err := <-serveErr:
+		log.Fatalln("STARTUP ERROR: Server failed to start:", err)
+	case <-ctx.Done():
+		stop() // A second Ctrl+C now kills the process immediately
+		health.setDraining()
+		if delay := cfg.Timeouts.DrainDelay.Duration; delay > 0 {
+			// Keep serving while load balancers poll /readyz, see the 503 and take us out of rotation
+			log.Printf("SHUTDOWN: reporting not ready for %s before closing listeners", delay)
+			time.Sleep(delay)
 		}
-	} else {
-		fmt.Println("  -> !! INSECURE CONNECTION !! No TLS detected.")
+
+		// QUIC connections drain in parallel with the TCP ones
+		var h3Done sync.WaitGroup
+		if h3 != nil {
+			h3Done.Add(1)
+			go func() {
+				defer h3Done.Done()
+				shutdownHTTP3(h3, drainTimeout)
+			}()
+		}
+		shutdownGracefully(server, drainTimeout, inFlight)
+		h3Done.Wait()
+		shutdownAdmin(admin)
 	}
 }
 
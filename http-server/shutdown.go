@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+/**
+ * FUTURE REFERENCE: GRACEFUL SHUTDOWN
+ * -----------------------------------
+ * server.Shutdown(ctx) does the heavy lifting:
+ *   1. Closes the listeners, so no new TCP connections (and therefore no new TLS handshakes) arrive.
+ *   2. Runs the RegisterOnShutdown hooks. http2.ConfigureServer registered one that sends
+ *      GOAWAY on every HTTP/2 connection, telling clients to open new streams elsewhere.
+ *   3. Waits until every connection is idle, i.e. every in-flight request has finished.
+ * Hijacked connections are invisible to step 3. h2c hijacks every HTTP/2 connection, so after
+ * Shutdown we also wait for the in-flight counter to reach zero.
+ * If ctx expires first we give up waiting, force-close the rest and report what we cut off.
+ */
+
+// inFlightTracker: Counts requests that are currently inside a handler
+type inFlightTracker struct {
+	n atomic.Int64
+}
+
+// middleware: Increments the counter for the lifetime of each request
+func (t *inFlightTracker) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.n.Add(1)
+		defer t.n.Add(-1)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// count: Number of requests being served right now
+func (t *inFlightTracker) count() int64 {
+	return t.n.Load()
+}
+
+// shutdownGracefully: Drains server for at most drainTimeout and returns how many requests were cut off
+func shutdownGracefully(server *http.Server, drainTimeout time.Duration, inFlight *inFlightTracker) int64 {
+	log.Printf("SHUTDOWN: draining %d in-flight requests (deadline %s)", inFlight.count(), drainTimeout)
+
+	ctx, cancel := context.WithTimeout(context.Background(), drainTimeout)
+	defer cancel()
+
+	err := server.Shutdown(ctx)
+	if err == nil {
+		err = waitIdle(ctx, inFlight)
+	}
+	if err == nil {
+		log.Println("SHUTDOWN: all requests finished, bye")
+		return 0
+	}
+
+	// The deadline passed (or shutdown failed): whatever is still running gets cut off
+	cutOff := inFlight.count()
+	if !errors.Is(err, context.DeadlineExceeded) {
+		log.Printf("SHUTDOWN ERROR: %v", err)
+	}
+	if err := server.Close(); err != nil {
+		log.Printf("SHUTDOWN ERROR: force close: %v", err)
+	}
+	log.Printf("SHUTDOWN: drain deadline exceeded, %d requests were cut off", cutOff)
+	return cutOff
+}
+
+// waitIdle: Polls until no request is in flight or ctx is done
+func waitIdle(ctx context.Context, inFlight *inFlightTracker) error {
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+	for inFlight.count() > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+/**
+ * FUTURE REFERENCE: TLS PROFILES
+ * ------------------------------
+ * Instead of hand-picking versions and ciphers in code, the server starts from a named profile
+ * (loosely following Mozilla's server side TLS guidelines):
+ *   - "modern":       TLS 1.3 only. Go fixes the TLS 1.3 cipher suites, so there is nothing to pick.
+ *   - "intermediate": TLS 1.2 + 1.3 with forward secret AEAD ciphers only. The default.
+ *   - "custom":       intermediate, with every field overridable (versions, ciphers, curves, ALPN).
+ * Everything is validated at startup so a typo fails fast instead of at the first handshake.
+ */
+
+// tlsSettings: Raw, unvalidated profile settings (empty fields keep the base profile's value)
+type tlsSettings struct {
+	Profile      string   `json:"profile"`
+	MinVersion   string   `json:"min_version,omitempty"`   // "1.2" or "1.3"
+	MaxVersion   string   `json:"max_version,omitempty"`   // "1.2" or "1.3"
+	CipherSuites []string `json:"cipher_suites,omitempty"` // Go names, e.g. TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256
+	Curves       []string `json:"curves,omitempty"`        // X25519MLKEM768, X25519, P256, P384, P521
+	ALPN         []string `json:"alpn,omitempty"`          // h2, http/1.1
+}
+
+// tlsProfile: The validated result, ready to be applied to a tls.Config
+type tlsProfile struct {
+	Name             string
+	MinVersion       uint16
+	MaxVersion       uint16
+	CipherSuites     []uint16 // Only affects TLS 1.2
+	CurvePreferences []tls.CurveID
+	NextProtos       []string
+}
+
+// modernTLSProfile: TLS 1.3 only
+func modernTLSProfile() tlsProfile {
+	return tlsProfile{
+		Name:             "modern",
+		MinVersion:       tls.VersionTLS13,
+		MaxVersion:       tls.VersionTLS13,
+		CurvePreferences: []tls.CurveID{tls.X25519MLKEM768, tls.X25519, tls.CurveP256, tls.CurveP384},
+		NextProtos:       []string{"h2", "http/1.1"},
+	}
+}
+
+// intermediateTLSProfile: TLS 1.2 + 1.3, matching the server's original MinVersion
+func intermediateTLSProfile() tlsProfile {
+	return tlsProfile{
+		Name:       "intermediate",
+		MinVersion: tls.VersionTLS12,
+		MaxVersion: tls.VersionTLS13,
+		CipherSuites: []uint16{
+			tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256,
+			tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256,
+		},
+		CurvePreferences: []tls.CurveID{tls.X25519MLKEM768, tls.X25519, tls.CurveP256, tls.CurveP384},
+		NextProtos:       []string{"h2", "http/1.1"},
+	}
+}
+
+// buildTLSProfile: Resolves the named profile and validates any custom overrides
+func buildTLSProfile(s tlsSettings) (tlsProfile, error) {
+	overridden := s.MinVersion != "" || s.MaxVersion != "" || len(s.CipherSuites) > 0 || len(s.Curves) > 0 || len(s.ALPN) > 0
+
+	var p tlsProfile
+	switch s.Profile {
+	case "modern":
+		p = modernTLSProfile()
+	case "", "intermediate":
+		p = intermediateTLSProfile()
+	case "custom":
+		p = intermediateTLSProfile()
+		p.Name = "custom"
+	default:
+		return p, fmt.Errorf("unknown TLS profile %q (want modern, intermediate or custom)", s.Profile)
+	}
+	if overridden && p.Name != "custom" {
+		return p, fmt.Errorf("TLS profile %q cannot be overridden, use the \"custom\" profile", p.Name)
+	}
+
+	var err error
+	if s.MinVersion != "" {
+		if p.MinVersion, err = parseTLSVersion(s.MinVersion); err != nil {
+			return p, err
+		}
+	}
+	if s.MaxVersion != "" {
+		if p.MaxVersion, err = parseTLSVersion(s.MaxVersion); err != nil {
+			return p, err
+		}
+	}
+	if p.MinVersion > p.MaxVersion {
+		return p, fmt.Errorf("TLS min version %s is above max version %s", getTLSVersionName(p.MinVersion), getTLSVersionName(p.MaxVersion))
+	}
+
+	if len(s.CipherSuites) > 0 {
+		if p.MinVersion == tls.VersionTLS13 {
+			return p, errors.New("cipher suites cannot be configured when only TLS 1.3 is enabled")
+		}
+		if p.CipherSuites, err = parseCipherSuites(s.CipherSuites); err != nil {
+			return p, err
+		}
+	}
+	if p.MinVersion == tls.VersionTLS13 {
+		p.CipherSuites = nil
+	}
+
+	if len(s.Curves) > 0 {
+		if p.CurvePreferences, err = parseCurves(s.Curves); err != nil {
+			return p, err
+		}
+	}
+
+	if len(s.ALPN) > 0 {
+		for _, proto := range s.ALPN {
+			if proto != "h2" && proto != "http/1.1" {
+				return p, fmt.Errorf("unsupported ALPN protocol %q (want h2 or http/1.1)", proto)
+			}
+		}
+		p.NextProtos = s.ALPN
+	}
+	return p, nil
+}
+
+// apply: Copies the profile onto cfg
+func (p tlsProfile) apply(cfg *tls.Config) {
+	cfg.MinVersion = p.MinVersion
+	cfg.MaxVersion = p.MaxVersion
+	cfg.CipherSuites = p.CipherSuites
+	cfg.CurvePreferences = p.CurvePreferences
+	cfg.NextProtos = p.NextProtos
+}
+
+// http2Enabled: Whether the profile offers h2 via ALPN
+func (p tlsProfile) http2Enabled() bool {
+	return slices.Contains(p.NextProtos, "h2")
+}
+
+// report: Human readable summary printed at startup
+func (p tlsProfile) report() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "TLS profile %q: %s - %s\n", p.Name, getTLSVersionName(p.MinVersion), getTLSVersionName(p.MaxVersion))
+
+	if len(p.CipherSuites) > 0 {
+		names := make([]string, len(p.CipherSuites))
+		for i, id := range p.CipherSuites {
+			names[i] = tls.CipherSuiteName(id)
+		}
+		fmt.Fprintf(&b, "  -> Cipher suites (TLS 1.2): %s\n", strings.Join(names, ", "))
+	}
+	if p.MaxVersion == tls.VersionTLS13 {
+		b.WriteString("  -> Cipher suites (TLS 1.3): chosen by Go (AES-GCM, ChaCha20-Poly1305)\n")
+	}
+
+	curves := make([]string, len(p.CurvePreferences))
+	for i, id := range p.CurvePreferences {
+		curves[i] = id.String()
+	}
+	fmt.Fprintf(&b, "  -> Curves: %s\n", strings.Join(curves, ", "))
+	fmt.Fprintf(&b, "  -> ALPN: %s", strings.Join(p.NextProtos, ", "))
+	return b.String()
+}
+
+// parseTLSVersion: "1.2" / "1.3" (with or without a "TLS" prefix). Older versions are refused.
+func parseTLSVersion(s string) (uint16, error) {
+	switch strings.TrimPrefix(strings.ToUpper(strings.ReplaceAll(s, " ", "")), "TLS") {
+	case "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	case "1.0", "1.1":
+		return 0, fmt.Errorf("TLS version %q is deprecated and not allowed", s)
+	default:
+		return 0, fmt.Errorf("unknown TLS version %q (want 1.2 or 1.3)", s)
+	}
+}
+
+// parseCipherSuites: Accepts Go's secure TLS 1.2 suite names and rejects the insecure ones by name
+func parseCipherSuites(names []string) ([]uint16, error) {
+	ids := make([]uint16, 0, len(names))
+	for _, name := range names {
+		id, ok := cipherSuiteID(name, tls.CipherSuites())
+		if !ok {
+			if _, insecure := cipherSuiteID(name, tls.InsecureCipherSuites()); insecure {
+				return nil, fmt.Errorf("cipher suite %s is insecure and not allowed", name)
+			}
+			return nil, fmt.Errorf("unknown cipher suite %q", name)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// cipherSuiteID: Looks up a TLS 1.2 suite by name in the given list
+func cipherSuiteID(name string, suites []*tls.CipherSuite) (uint16, bool) {
+	for _, suite := range suites {
+		if suite.Name == name && slices.Contains(suite.SupportedVersions, tls.VersionTLS12) {
+			return suite.ID, true
+		}
+	}
+	return 0, false
+}
+
+// parseCurves: Accepts X25519MLKEM768, X25519, P256 / P-256 / CurveP256, P384, P521
+func parseCurves(names []string) ([]tls.CurveID, error) {
+	known := []tls.CurveID{tls.X25519MLKEM768, tls.X25519, tls.CurveP256, tls.CurveP384, tls.CurveP521}
+
+	ids := make([]tls.CurveID, 0, len(names))
+	for _, name := range names {
+		normalized := strings.ReplaceAll(strings.TrimPrefix(name, "Curve"), "-", "")
+		i := slices.IndexFunc(known, func(id tls.CurveID) bool {
+			return strings.TrimPrefix(id.String(), "Curve") == normalized
+		})
+		if i < 0 {
+			return nil, fmt.Errorf("unknown curve %q", name)
+		}
+		ids = append(ids, known[i])
+	}
+	return ids, nil
+}